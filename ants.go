@@ -1,6 +1,7 @@
 package ants
 
 import (
+	"context"
 	"errors"
 	"log"
 	"math"
@@ -13,6 +14,7 @@ import (
 const (
 	DefaultAntsPoolSize = math.MaxInt32 //默认池子大小
 	DefaultCleanIntervalTime = time.Second //worker的默认过期时间
+	shutdownPollInterval = 10 * time.Millisecond //Shutdown检查worker是否全部退出的时间间隔
 )
 //---------------------------------------------------------------------------
 
@@ -29,6 +31,13 @@ var (
 	ErrInvalidPoolExpiry = errors.New("invalid expiry for pool")
 	ErrPoolClosed = errors.New("this pool has been closed")
 	ErrPoolOverload = errors.New("too many goroutines blocked on submit or Nonblocking is set")
+	ErrInvalidQueueSize = errors.New("invalid size for task queue")
+	ErrNilTask = errors.New("task must not be nil")
+	ErrInvalidAutoscaler = errors.New("invalid autoscaler config, 0 < Min <= Max is required")
+	ErrInvalidMaxWaitDuration = errors.New("invalid max wait duration for pool")
+	ErrSubmitTimeout = errors.New("timed out waiting for an idle worker")
+	ErrInvalidMinIdleWorkers = errors.New("invalid number of min idle workers for pool")
+	ErrTaskDiscarded = errors.New("task has been discarded by the rejection policy")
 	//确定worker的通道是否该是缓冲通道，灵感来自fasthttp 主要取决于P的数量，P为1则...大于1则...
 	workerChanCap = func() int {
 		if runtime.GOMAXPROCS(0) == 1 {
@@ -56,7 +65,23 @@ type Logger interface {
 func Submit(task func()) error {
 	return defaultAntsPool.Submit(task)
 }
-//返回当前默认池子运行的goroutines的数量
+//批量提交任务到默认池子中，返回成功提交的任务个数
+func SubmitBatch(tasks []func()) (int, error) {
+	return defaultAntsPool.SubmitBatch(tasks)
+}
+//以priority的优先级提交任务到默认池子中
+func SubmitWithPriority(task func(), priority int) error {
+	return defaultAntsPool.SubmitWithPriority(task, priority)
+}
+//提交任务到默认池子中，等待空闲worker超过timeout则返回ErrSubmitTimeout
+func SubmitTimeout(task func(), timeout time.Duration) error {
+	return defaultAntsPool.SubmitTimeout(task, timeout)
+}
+//提交任务到默认池子中，等待空闲worker的过程可以通过ctx取消
+func SubmitContext(ctx context.Context, task func(context.Context)) error {
+	return defaultAntsPool.SubmitContext(ctx, task)
+}
+//返回当前默认池子运行的goroutines的数量，包括空闲的worker
 func Running() int {
 	return defaultAntsPool.Running()
 }
@@ -64,7 +89,23 @@ func Running() int {
 func Cap() int {
 	return defaultAntsPool.Cap()
 }
-//返回可以继续创建的worker数量
+//返回默认池子当前的统计快照
+func Stats() PoolStats {
+	return defaultAntsPool.Stats()
+}
+//返回默认池子当前存活的worker数量，包括空闲的
+func Alive() int {
+	return defaultAntsPool.Alive()
+}
+//返回默认池子正在执行任务的worker数量
+func Busy() int {
+	return defaultAntsPool.Busy()
+}
+//返回默认池子空闲的worker数量
+func Idle() int {
+	return defaultAntsPool.Idle()
+}
+//返回默认池子还可以同时执行的任务数，即容量减去正在执行任务的worker数量
 func Free() int {
 	return defaultAntsPool.Free()
 }
@@ -73,6 +114,16 @@ func Release() {
 	defaultAntsPool.Release()
 }
 
+//优雅关闭默认的goroutine池子，等待正在执行的任务结束，ctx结束时返回还没有执行结束的任务数
+func Shutdown(ctx context.Context) (int, error) {
+	return defaultAntsPool.Shutdown(ctx)
+}
+
+//优雅关闭默认的goroutine池子，最多等待timeout
+func ReleaseTimeout(timeout time.Duration) (int, error) {
+	return defaultAntsPool.ReleaseTimeout(timeout)
+}
+
 // Reboot reboots the default pool.
 func Reboot() {
 	defaultAntsPool.Reboot()
@@ -24,9 +24,13 @@ package ants
 
 import (
 	"runtime"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/panjf2000/ants/v2/internal"
 )
 
 const (
@@ -144,3 +148,241 @@ func BenchmarkAntsPoolThroughput(b *testing.B) {
 	}
 	b.StopTimer()
 }
+
+const (
+	fairnessPoolSize   = 8
+	fairnessSubmitters = 64
+)
+
+//condPool是改动之前(924b751)Pool阻塞提交路径的一份测试用的拷贝：retrieveWorker在池子满载时通过sync.Cond等待，
+//revertWorker把worker放回空闲栈之后只是Signal唤醒一个等待者，被唤醒的等待者还要与新来的提交者重新抢锁去取worker，
+//不保证先来先服务。这里只保留了基准测试用得到的部分(没有定期清理、非阻塞模式以及MaxBlockingTasks)，
+//用来与BenchmarkBlockedSubmitFIFO对比改动前后提交者的等待时间
+type condPool struct {
+	capacity    int32
+	running     int32
+	closed      int32
+	workers     []*condWorker
+	lock        sync.Locker
+	cond        *sync.Cond
+	workerCache sync.Pool
+}
+
+type condWorker struct {
+	pool *condPool
+	task chan func()
+}
+
+func newCondPool(size int) *condPool {
+	p := &condPool{capacity: int32(size), lock: internal.NewSpinLock()}
+	p.cond = sync.NewCond(p.lock)
+	p.workerCache.New = func() interface{} {
+		return &condWorker{pool: p, task: make(chan func(), workerChanCap)}
+	}
+	return p
+}
+
+func (p *condPool) Submit(task func()) error {
+	p.retrieveWorker().task <- task
+	return nil
+}
+
+func (p *condPool) Release() {
+	atomic.StoreInt32(&p.closed, 1)
+	p.lock.Lock()
+	for _, w := range p.workers {
+		w.task <- nil
+	}
+	p.workers = nil
+	p.lock.Unlock()
+}
+
+func (p *condPool) detach() *condWorker {
+	n := len(p.workers)
+	if n == 0 {
+		return nil
+	}
+	w := p.workers[n-1]
+	p.workers = p.workers[:n-1]
+	return w
+}
+
+//与原来的retrieveWorker一样：有空闲worker就取走，没有就在容量允许时新建，否则在cond上等待，
+//被唤醒之后再去抢空闲worker，抢不到就继续等
+func (p *condPool) retrieveWorker() *condWorker {
+	spawn := func() *condWorker {
+		w := p.workerCache.Get().(*condWorker)
+		w.run()
+		return w
+	}
+	p.lock.Lock()
+	if w := p.detach(); w != nil {
+		p.lock.Unlock()
+		return w
+	}
+	if atomic.LoadInt32(&p.running) < atomic.LoadInt32(&p.capacity) {
+		p.lock.Unlock()
+		return spawn()
+	}
+	for {
+		p.cond.Wait()
+		if atomic.LoadInt32(&p.running) == 0 {
+			p.lock.Unlock()
+			return spawn()
+		}
+		if w := p.detach(); w != nil {
+			p.lock.Unlock()
+			return w
+		}
+	}
+}
+
+//与原来的revertWorker一样：把worker放回空闲栈，然后唤醒一个等待者
+func (p *condPool) revertWorker(w *condWorker) bool {
+	if atomic.LoadInt32(&p.closed) == 1 {
+		return false
+	}
+	p.lock.Lock()
+	p.workers = append(p.workers, w)
+	p.cond.Signal()
+	p.lock.Unlock()
+	return true
+}
+
+func (w *condWorker) run() {
+	atomic.AddInt32(&w.pool.running, 1)
+	go func() {
+		defer func() {
+			atomic.AddInt32(&w.pool.running, -1)
+			w.pool.workerCache.Put(w)
+		}()
+		for f := range w.task {
+			if f == nil {
+				return
+			}
+			f()
+			if !w.pool.revertWorker(w) {
+				return
+			}
+		}
+	}()
+}
+
+//让fairnessSubmitters个提交者持续向只有fairnessPoolSize个名额的池子提交任务，
+//统计每个任务从提交到开始执行的等待时间，报告其中位数、p99以及最大值
+func benchmarkBlockedSubmit(b *testing.B, submit func(task func()) error) {
+	waits := make([]time.Duration, b.N)
+	var (
+		next int64 = -1
+		wg   sync.WaitGroup
+	)
+	wg.Add(b.N)
+	b.ResetTimer()
+	var submitters sync.WaitGroup
+	for i := 0; i < fairnessSubmitters; i++ {
+		submitters.Add(1)
+		go func() {
+			defer submitters.Done()
+			for {
+				n := atomic.AddInt64(&next, 1)
+				if n >= int64(b.N) {
+					return
+				}
+				since := time.Now()
+				_ = submit(func() {
+					waits[n] = time.Since(since)
+					for i := 0; i < 100; i++ {
+						runtime.Gosched()
+					}
+					wg.Done()
+				})
+			}
+		}()
+	}
+	submitters.Wait()
+	wg.Wait()
+	b.StopTimer()
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+	b.ReportMetric(float64(waits[len(waits)/2].Microseconds()), "p50-wait-µs")
+	b.ReportMetric(float64(waits[len(waits)*99/100].Microseconds()), "p99-wait-µs")
+	b.ReportMetric(float64(waits[len(waits)-1].Microseconds()), "max-wait-µs")
+}
+
+func BenchmarkBlockedSubmitCond(b *testing.B) {
+	p := newCondPool(fairnessPoolSize)
+	defer p.Release()
+	benchmarkBlockedSubmit(b, p.Submit)
+}
+
+func BenchmarkBlockedSubmitFIFO(b *testing.B) {
+	p, _ := NewPool(fairnessPoolSize, WithExpiryDuration(DefaultExpiredTime))
+	defer p.Release()
+	benchmarkBlockedSubmit(b, p.Submit)
+}
+
+const batchSize = 1000
+
+func BenchmarkAntsPoolSubmitSmallTasks(b *testing.B) {
+	var wg sync.WaitGroup
+	p, _ := NewPool(batchSize, WithExpiryDuration(DefaultExpiredTime))
+	defer p.Release()
+	task := func() { wg.Done() }
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wg.Add(batchSize)
+		for j := 0; j < batchSize; j++ {
+			_ = p.Submit(task)
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkAntsPoolSubmitBatchSmallTasks(b *testing.B) {
+	var wg sync.WaitGroup
+	p, _ := NewPool(batchSize, WithExpiryDuration(DefaultExpiredTime))
+	defer p.Release()
+	tasks := make([]func(), batchSize)
+	for i := range tasks {
+		tasks[i] = func() { wg.Done() }
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wg.Add(batchSize)
+		_, _ = p.SubmitBatch(tasks)
+		wg.Wait()
+	}
+}
+
+func BenchmarkAntsPoolWithFuncInvokeSmallTasks(b *testing.B) {
+	var wg sync.WaitGroup
+	p, _ := NewPoolWithFunc(batchSize, func(interface{}) { wg.Done() }, WithExpiryDuration(DefaultExpiredTime))
+	defer p.Release()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wg.Add(batchSize)
+		for j := 0; j < batchSize; j++ {
+			_ = p.Invoke(j)
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkAntsPoolWithFuncInvokeBatchSmallTasks(b *testing.B) {
+	var wg sync.WaitGroup
+	p, _ := NewPoolWithFunc(batchSize, func(interface{}) { wg.Done() }, WithExpiryDuration(DefaultExpiredTime))
+	defer p.Release()
+	args := make([]interface{}, batchSize)
+	for i := range args {
+		args[i] = i
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wg.Add(batchSize)
+		_, _ = p.InvokeBatch(args)
+		wg.Wait()
+	}
+}
@@ -23,6 +23,7 @@
 package ants
 
 import (
+	"context"
 	"log"
 	"os"
 	"runtime"
@@ -266,6 +267,14 @@ func TestPanicHandler(t *testing.T) {
 	assert.EqualValues(t, 0, p1.Running(), "pool should be empty after panic")
 }
 
+//容量为size的PreAlloc池子(没有设置MinIdleWorkers)创建时预先开启的worker个数
+func preAllocWorkers(size int) int {
+	if n := runtime.GOMAXPROCS(0); n < size {
+		return n
+	}
+	return size
+}
+
 func TestPanicHandlerPreMalloc(t *testing.T) {
 	var panicCounter int64
 	var wg sync.WaitGroup
@@ -283,7 +292,7 @@ func TestPanicHandlerPreMalloc(t *testing.T) {
 	wg.Wait()
 	c := atomic.LoadInt64(&panicCounter)
 	assert.EqualValuesf(t, 1, c, "panic handler didn't work, panicCounter: %d", c)
-	assert.EqualValues(t, 0, p0.Running(), "pool should be empty after panic")
+	assert.EqualValues(t, preAllocWorkers(10)-1, p0.Running(), "only the panicked worker should exit")
 	p1, err := NewPoolWithFunc(10, func(p interface{}) { panic(p) }, WithPanicHandler(func(p interface{}) {
 		defer wg.Done()
 		atomic.AddInt64(&panicCounter, 1)
@@ -344,7 +353,7 @@ func TestPurge(t *testing.T) {
 	defer p1.Release()
 	_ = p1.Invoke(1)
 	time.Sleep(3 * DefaultCleanIntervalTime)
-	assert.EqualValues(t, 0, p.Running(), "all p should be purged")
+	assert.EqualValues(t, 0, p1.Running(), "all p should be purged")
 }
 
 func TestPurgePreMalloc(t *testing.T) {
@@ -354,12 +363,14 @@ func TestPurgePreMalloc(t *testing.T) {
 	_ = p.Submit(demoFunc)
 	time.Sleep(3 * DefaultCleanIntervalTime)
 	assert.EqualValues(t, 0, p.Running(), "all p should be purged")
-	p1, err := NewPoolWithFunc(10, demoPoolFunc)
+	p1, err := NewPoolWithFunc(10, demoPoolFunc, WithPreAlloc(true))
 	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
 	defer p1.Release()
 	_ = p1.Invoke(1)
+	time.Sleep(2 * time.Duration(Param) * time.Millisecond)
+	assert.EqualValues(t, preAllocWorkers(10), p1.Stats().Idle, "the worker should be put back into the loop queue")
 	time.Sleep(3 * DefaultCleanIntervalTime)
-	assert.EqualValues(t, 0, p.Running(), "all p should be purged")
+	assert.EqualValues(t, 0, p1.Running(), "all p should be purged")
 }
 
 func TestNonblockingSubmit(t *testing.T) {
@@ -482,6 +493,597 @@ func TestMaxBlockingSubmitWithFunc(t *testing.T) {
 	}
 }
 
+func TestSubmitContext(t *testing.T) {
+	p, err := NewPool(1)
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	ch := make(chan struct{})
+	assert.NoError(t, p.Submit(func() { <-ch }), "submit when pool is not full shouldn't return error")
+	// p is full now, the submitter should give up once the deadline exceeded.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, p.SubmitContext(ctx, func(context.Context) {}),
+		"blocking submit should return ctx.Err() when ctx is done")
+	p.lock.Lock()
+	assert.EqualValues(t, 0, p.blockingNum, "blocking num should be reset after giving up")
+	assert.EqualValues(t, 0, p.waiters.len(), "waiter should be removed after giving up")
+	p.lock.Unlock()
+	assert.Equal(t, context.DeadlineExceeded, p.SubmitContext(ctx, func(context.Context) {}),
+		"submit with a done ctx should return ctx.Err()")
+	close(ch)
+
+	// the context is passed to the task.
+	ctx, cancel = context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	assert.NoError(t, p.SubmitContext(ctx, func(ctx context.Context) {
+		<-ctx.Done()
+		done <- ctx.Err()
+	}), "submit when pool is not full shouldn't return error")
+	cancel()
+	assert.Equal(t, context.Canceled, <-done, "task should observe the cancellation of ctx")
+}
+
+func TestInvokeContext(t *testing.T) {
+	ch := make(chan struct{})
+	done := make(chan error, 1)
+	p, err := NewPoolWithFuncContext(1, func(ctx context.Context, arg interface{}) {
+		if arg == "wait" {
+			<-ch
+			return
+		}
+		<-ctx.Done()
+		done <- ctx.Err()
+	})
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer p.Release()
+	assert.NoError(t, p.Invoke("wait"), "invoke when pool is not full shouldn't return error")
+	// p is full now, the invoker should give up once ctx is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.InvokeContext(ctx, "wait")
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	assert.Equal(t, context.Canceled, <-errCh, "blocking invoke should return ctx.Err() when ctx is done")
+	p.lock.Lock()
+	assert.EqualValues(t, 0, p.blockingNum, "blocking num should be reset after giving up")
+	p.lock.Unlock()
+	close(ch)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	assert.NoError(t, p.InvokeContext(ctx, "ctx"), "invoke when pool is not full shouldn't return error")
+	cancel()
+	assert.Equal(t, context.Canceled, <-done, "pool func should observe the cancellation of ctx")
+}
+
+func TestShutdown(t *testing.T) {
+	p, err := NewPool(10)
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	ch := make(chan struct{})
+	for i := 0; i < 5; i++ {
+		_ = p.Submit(demoFunc)
+	}
+	assert.NoError(t, p.Submit(func() { <-ch }), "submit when pool is not full shouldn't return error")
+	n, err := p.ReleaseTimeout(100 * time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, err, "shutdown should time out while a task is running")
+	assert.EqualValues(t, 1, n, "only the blocked task should be still running")
+	assert.EqualError(t, p.Submit(demoFunc), ErrPoolClosed.Error(), "pool should be closed")
+	close(ch)
+	n, err = p.Shutdown(context.Background())
+	assert.NoError(t, err, "shutdown should succeed after all tasks finished")
+	assert.EqualValues(t, 0, n)
+	assert.EqualValues(t, 0, p.Running(), "all workers should exit after shutdown")
+
+	ch = make(chan struct{})
+	p1, err := NewPoolWithFunc(10, func(i interface{}) { <-ch })
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	assert.NoError(t, p1.Invoke(1), "invoke when pool is not full shouldn't return error")
+	n, err = p1.ReleaseTimeout(100 * time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, err, "shutdown should time out while a task is running")
+	assert.EqualValues(t, 1, n, "only the blocked task should be still running")
+	close(ch)
+	n, err = p1.Shutdown(context.Background())
+	assert.NoError(t, err, "shutdown should succeed after all tasks finished")
+	assert.EqualValues(t, 0, n)
+
+	//任务队列中排队的任务也算还没有执行结束
+	ch = make(chan struct{})
+	p2, err := NewPool(1, WithQueueSize(5))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, p2.Submit(func() { <-ch }), "submit when queue is not full shouldn't return error")
+	}
+	n, err = p2.ReleaseTimeout(100 * time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, err, "shutdown should time out while tasks are queued")
+	assert.EqualValues(t, 6, n, "both the running and the queued tasks should be reported")
+	close(ch)
+	n, err = p2.Shutdown(context.Background())
+	assert.NoError(t, err, "shutdown should succeed after all tasks finished")
+	assert.EqualValues(t, 0, n)
+
+	defer Reboot()
+	Reboot()
+	assert.NoError(t, Submit(demoFunc), "default pool should be opened")
+	n, err = ReleaseTimeout(time.Second)
+	assert.NoError(t, err, "shutdown default pool should succeed after all tasks finished")
+	assert.EqualValues(t, 0, n)
+}
+
+func TestReleaseWakesBlockedSubmitters(t *testing.T) {
+	poolSize := 10
+	p, err := NewPool(poolSize, WithMaxBlockingTasks(2))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	ch := make(chan struct{})
+	defer close(ch)
+	for i := 0; i < poolSize; i++ {
+		assert.NoError(t, p.Submit(func() { <-ch }), "submit when pool is not full shouldn't return error")
+	}
+	// p is full now.
+	errCh := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errCh <- p.Submit(demoFunc)
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualError(t, p.Submit(demoFunc), ErrPoolOverload.Error(),
+		"blocking submit when pool reach max blocking submit should return ErrPoolOverload")
+	p.Release()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errCh:
+			assert.EqualError(t, err, ErrPoolClosed.Error(), "blocked submitters should get ErrPoolClosed")
+		case <-time.After(time.Second):
+			t.Fatal("blocked submitters should be woken up by Release")
+		}
+	}
+	assert.EqualValues(t, poolSize, p.Running(), "no worker should be spawned on a closed pool")
+
+	p1, err := NewPoolWithFunc(poolSize, func(i interface{}) { <-ch }, WithMaxBlockingTasks(2))
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	for i := 0; i < poolSize; i++ {
+		assert.NoError(t, p1.Invoke(i), "invoke when pool is not full shouldn't return error")
+	}
+	for i := 0; i < 2; i++ {
+		go func() {
+			errCh <- p1.Invoke(Param)
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualError(t, p1.Invoke(Param), ErrPoolOverload.Error(),
+		"blocking invoke when pool reach max blocking submit should return ErrPoolOverload")
+	p1.Release()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errCh:
+			assert.EqualError(t, err, ErrPoolClosed.Error(), "blocked invokers should get ErrPoolClosed")
+		case <-time.After(time.Second):
+			t.Fatal("blocked invokers should be woken up by Release")
+		}
+	}
+	assert.EqualValues(t, poolSize, p1.Running(), "no worker should be spawned on a closed pool")
+}
+
+func TestTaskQueueSubmit(t *testing.T) {
+	poolSize, queueSize := 2, 3
+	p, err := NewPool(poolSize, WithQueueSize(queueSize))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	var wg sync.WaitGroup
+	var sum int32
+	ch := make(chan struct{})
+	for i := 0; i < poolSize; i++ {
+		wg.Add(1)
+		assert.NoError(t, p.Submit(func() {
+			<-ch
+			wg.Done()
+		}), "submit when pool is not full shouldn't return error")
+	}
+	// p is full now, the following tasks should be queued without blocking.
+	for i := 0; i < queueSize; i++ {
+		wg.Add(1)
+		assert.NoError(t, p.Submit(func() {
+			atomic.AddInt32(&sum, 1)
+			wg.Done()
+		}), "submit when queue is not full shouldn't return error")
+	}
+	assert.EqualValues(t, queueSize, p.QueueLen(), "tasks should be queued")
+	assert.EqualValues(t, poolSize, p.Running(), "queued tasks shouldn't spawn workers")
+	assert.EqualError(t, p.Submit(demoFunc), ErrPoolOverload.Error(),
+		"submit when queue is full should return ErrPoolOverload")
+	close(ch)
+	wg.Wait()
+	assert.EqualValues(t, queueSize, atomic.LoadInt32(&sum), "all queued tasks should be run")
+	assert.EqualValues(t, 0, p.QueueLen(), "queue should be drained")
+
+	// queued tasks survive a panicking worker.
+	p1, err := NewPool(1, WithQueueSize(1), WithPanicHandler(func(interface{}) {}))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p1.Release()
+	ch = make(chan struct{})
+	assert.NoError(t, p1.Submit(func() {
+		<-ch
+		panic("Oops!")
+	}))
+	wg.Add(1)
+	assert.NoError(t, p1.Submit(wg.Done), "submit when queue is not full shouldn't return error")
+	close(ch)
+	wg.Wait()
+}
+
+func TestTaskQueueInvoke(t *testing.T) {
+	poolSize, queueSize := 2, 3
+	var wg sync.WaitGroup
+	var sum int32
+	ch := make(chan struct{})
+	p, err := NewPoolWithFunc(poolSize, func(i interface{}) {
+		if i == "wait" {
+			<-ch
+		} else {
+			atomic.AddInt32(&sum, int32(i.(int)))
+		}
+		wg.Done()
+	}, WithQueueSize(queueSize))
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer p.Release()
+	for i := 0; i < poolSize; i++ {
+		wg.Add(1)
+		assert.NoError(t, p.Invoke("wait"), "invoke when pool is not full shouldn't return error")
+	}
+	for i := 0; i < queueSize; i++ {
+		wg.Add(1)
+		assert.NoError(t, p.Invoke(1), "invoke when queue is not full shouldn't return error")
+	}
+	assert.EqualValues(t, queueSize, p.QueueLen(), "invocations should be queued")
+	assert.EqualError(t, p.Invoke(1), ErrPoolOverload.Error(),
+		"invoke when queue is full should return ErrPoolOverload")
+	close(ch)
+	wg.Wait()
+	assert.EqualValues(t, queueSize, atomic.LoadInt32(&sum), "all queued invocations should be run")
+
+	_, err = NewPool(1, WithQueueSize(-1))
+	assert.EqualError(t, err, ErrInvalidQueueSize.Error())
+	_, err = NewPoolWithFunc(1, demoPoolFunc, WithQueueSize(-1))
+	assert.EqualError(t, err, ErrInvalidQueueSize.Error())
+}
+
+func TestRejectionPolicy(t *testing.T) {
+	ch := make(chan struct{})
+	defer close(ch)
+	block := func() { <-ch }
+
+	// CallerRunsPolicy runs the task on the submitting goroutine.
+	p, err := NewPool(1, WithNonblocking(true), WithRejectionPolicy(CallerRunsPolicy))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	assert.NoError(t, p.Submit(block))
+	ran := false
+	assert.NoError(t, p.Submit(func() { ran = true }), "CallerRunsPolicy shouldn't return error")
+	assert.True(t, ran, "task should be run by the caller")
+
+	// DiscardPolicy drops the task silently.
+	p, err = NewPool(1, WithNonblocking(true), WithRejectionPolicy(DiscardPolicy))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	assert.NoError(t, p.Submit(block))
+	assert.NoError(t, p.Submit(demoFunc), "DiscardPolicy shouldn't return error")
+	assert.EqualValues(t, 1, p.Discarded(), "discarded task should be counted")
+
+	// DiscardOldestPolicy drops the head of the task queue.
+	var order []int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	p, err = NewPool(1, WithQueueSize(2), WithRejectionPolicy(DiscardOldestPolicy))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	assert.NoError(t, p.Submit(func() { <-release }))
+	for i := 0; i < 3; i++ {
+		i := i
+		wg.Add(1)
+		assert.NoError(t, p.Submit(func() {
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			wg.Done()
+		}), "DiscardOldestPolicy shouldn't return error")
+	}
+	assert.EqualValues(t, 1, p.Discarded(), "discarded task should be counted")
+	wg.Done() // the discarded one
+	close(release)
+	wg.Wait()
+	assert.Equal(t, []int{1, 2}, order, "the oldest queued task should be discarded")
+
+	// DiscardOldestPolicy kicks out the longest waiting submitter.
+	p, err = NewPool(1, WithMaxBlockingTasks(1), WithRejectionPolicy(DiscardOldestPolicy))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	release = make(chan struct{})
+	assert.NoError(t, p.Submit(func() { <-release }))
+	errCh := make(chan error, 2)
+	go func() { errCh <- p.Submit(demoFunc) }()
+	time.Sleep(100 * time.Millisecond)
+	go func() { errCh <- p.Submit(demoFunc) }()
+	assert.EqualError(t, <-errCh, ErrPoolOverload.Error(), "the oldest waiting submitter should be discarded")
+	assert.EqualValues(t, 1, p.Discarded(), "discarded task should be counted")
+	close(release)
+	assert.NoError(t, <-errCh, "the newer submitter should get a worker")
+
+	// RejectionHandler receives the rejected task.
+	var rejected interface{}
+	p, err = NewPool(1, WithNonblocking(true), WithRejectionHandler(func(task interface{}) { rejected = task }))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	assert.NoError(t, p.Submit(block))
+	assert.NoError(t, p.Submit(demoFunc), "RejectionHandler shouldn't return error")
+	assert.NotNil(t, rejected, "rejection handler should receive the task")
+}
+
+func TestRejectionPolicyWithFunc(t *testing.T) {
+	ch := make(chan struct{})
+	defer close(ch)
+	var sum int32
+	pf := func(i interface{}) {
+		if i == "wait" {
+			<-ch
+			return
+		}
+		atomic.AddInt32(&sum, int32(i.(int)))
+	}
+
+	p, err := NewPoolWithFunc(1, pf, WithNonblocking(true), WithRejectionPolicy(CallerRunsPolicy))
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer p.Release()
+	assert.NoError(t, p.Invoke("wait"))
+	assert.NoError(t, p.Invoke(1), "CallerRunsPolicy shouldn't return error")
+	assert.EqualValues(t, 1, atomic.LoadInt32(&sum), "invocation should be run by the caller")
+
+	p, err = NewPoolWithFunc(1, pf, WithQueueSize(1), WithRejectionPolicy(DiscardOldestPolicy))
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer p.Release()
+	assert.NoError(t, p.Invoke("wait"))
+	assert.NoError(t, p.Invoke(10))
+	assert.NoError(t, p.Invoke(100), "DiscardOldestPolicy shouldn't return error")
+	assert.EqualValues(t, 1, p.Discarded(), "discarded invocation should be counted")
+	assert.EqualValues(t, 1, p.QueueLen())
+
+	var rejected interface{}
+	p, err = NewPoolWithFunc(1, pf, WithNonblocking(true), WithRejectionHandler(func(args interface{}) { rejected = args }))
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer p.Release()
+	assert.NoError(t, p.Invoke("wait"))
+	assert.NoError(t, p.Invoke(1000), "RejectionHandler shouldn't return error")
+	assert.EqualValues(t, 1000, rejected, "rejection handler should receive the args")
+}
+
+func TestPoolStats(t *testing.T) {
+	var wg sync.WaitGroup
+	p, err := NewPool(2, WithExpiryDuration(100*time.Millisecond), WithMaxBlockingTasks(1),
+		WithPanicHandler(func(interface{}) { wg.Done() }))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+
+	ch := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		assert.NoError(t, p.Submit(func() {
+			<-ch
+			time.Sleep(10 * time.Millisecond)
+			wg.Done()
+		}))
+	}
+	wg.Add(1)
+	go func() {
+		_ = p.Submit(func() { panic("Oops!") })
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualError(t, p.Submit(demoFunc), ErrPoolOverload.Error())
+	stats := p.Stats()
+	assert.EqualValues(t, 2, stats.Capacity)
+	assert.EqualValues(t, 2, stats.Running)
+	assert.EqualValues(t, 0, stats.Idle)
+	assert.EqualValues(t, 1, stats.Waiting, "one submitter should be waiting")
+	assert.EqualValues(t, 1, stats.Rejected, "one task should be rejected")
+	close(ch)
+	wg.Wait()
+
+	stats = p.Stats()
+	assert.EqualValues(t, 3, stats.Submitted)
+	assert.EqualValues(t, 2, stats.Completed)
+	assert.EqualValues(t, 1, stats.Panicked)
+	assert.EqualValues(t, 0, stats.Waiting)
+	assert.EqualValues(t, 2, stats.WorkersSpawned)
+	assert.True(t, stats.TaskDuration >= 20*time.Millisecond, "task duration should be accumulated")
+	assert.True(t, stats.WaitDuration >= 100*time.Millisecond, "wait duration should be accumulated")
+
+	time.Sleep(500 * time.Millisecond)
+	stats = p.Stats()
+	assert.EqualValues(t, 0, stats.Running, "all workers should be purged")
+	assert.EqualValues(t, 1, stats.WorkersPurged, "the idle worker should be purged")
+
+	p1, err := NewPoolWithFunc(2, demoPoolFunc, WithQueueSize(1))
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer p1.Release()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, p1.Invoke(10))
+	}
+	stats = p1.Stats()
+	assert.EqualValues(t, 3, stats.Submitted)
+	assert.EqualValues(t, 1, stats.Queued)
+	assert.EqualValues(t, 2, stats.WorkersSpawned)
+	time.Sleep(100 * time.Millisecond)
+	stats = p1.Stats()
+	assert.EqualValues(t, 3, stats.Completed)
+	assert.EqualValues(t, 2, stats.Idle)
+	assert.True(t, stats.TaskDuration >= 30*time.Millisecond, "task duration should be accumulated")
+
+	t.Logf("default pool stats: %+v", Stats())
+}
+
+func TestHooks(t *testing.T) {
+	var (
+		mu                                  sync.Mutex
+		submitted, rejected, started, ended int
+		panicked, spawned, expired          []uint64
+		maxWait, maxRun                     time.Duration
+	)
+	hooks := Hooks{
+		OnSubmit: func(interface{}) { mu.Lock(); submitted++; mu.Unlock() },
+		OnReject: func(interface{}) { mu.Lock(); rejected++; mu.Unlock() },
+		OnTaskStart: func(info TaskInfo) {
+			mu.Lock()
+			started++
+			if info.Wait > maxWait {
+				maxWait = info.Wait
+			}
+			mu.Unlock()
+		},
+		OnTaskEnd: func(info TaskInfo) {
+			mu.Lock()
+			ended++
+			if info.Run > maxRun {
+				maxRun = info.Run
+			}
+			mu.Unlock()
+		},
+		OnPanic:        func(id uint64, _ interface{}) { mu.Lock(); panicked = append(panicked, id); mu.Unlock() },
+		OnWorkerSpawn:  func(id uint64) { mu.Lock(); spawned = append(spawned, id); mu.Unlock() },
+		OnWorkerExpire: func(id uint64) { mu.Lock(); expired = append(expired, id); mu.Unlock() },
+	}
+	var wg sync.WaitGroup
+	p, err := NewPool(1, WithExpiryDuration(100*time.Millisecond), WithQueueSize(1), WithHooks(hooks),
+		WithPanicHandler(func(interface{}) { wg.Done() }))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+
+	wg.Add(2)
+	assert.NoError(t, p.Submit(func() {
+		time.Sleep(20 * time.Millisecond)
+		wg.Done()
+	}))
+	assert.NoError(t, p.Submit(func() { panic("Oops!") }))
+	assert.EqualError(t, p.Submit(demoFunc), ErrPoolOverload.Error())
+	wg.Wait()
+	wg.Add(1)
+	assert.NoError(t, p.Submit(wg.Done))
+	wg.Wait()
+	time.Sleep(500 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.EqualValues(t, 3, submitted)
+	assert.EqualValues(t, 1, rejected)
+	assert.EqualValues(t, 3, started)
+	assert.EqualValues(t, 2, ended, "the panicked task should not end normally")
+	assert.True(t, maxWait >= 20*time.Millisecond, "the queued task should wait for the running one")
+	assert.True(t, maxRun >= 20*time.Millisecond, "run duration should be reported")
+	assert.Equal(t, []uint64{1, 2}, spawned)
+	assert.Equal(t, []uint64{1}, panicked, "the first worker should run the queued task and panic")
+	assert.Equal(t, []uint64{2}, expired)
+}
+
+func TestHooksOrder(t *testing.T) {
+	//OnSubmit必须先于同一个任务的OnTaskStart，放入任务队列的任务也不例外
+	var submitted, started, violated int32
+	hooks := Hooks{
+		OnSubmit: func(interface{}) {
+			time.Sleep(time.Millisecond) //拉长提交与任务可见之间的窗口
+			atomic.AddInt32(&submitted, 1)
+		},
+		OnTaskStart: func(TaskInfo) {
+			if atomic.AddInt32(&started, 1) > atomic.LoadInt32(&submitted) {
+				atomic.AddInt32(&violated, 1)
+			}
+		},
+	}
+	p, err := NewPool(2, WithQueueSize(1000), WithHooks(hooks))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				_ = p.Submit(func() {})
+			}
+			_, _ = p.SubmitBatch([]func(){func() {}, func() {}, func() {}, func() {}})
+		}()
+	}
+	wg.Wait()
+	p.Wait()
+	assert.EqualValues(t, 0, atomic.LoadInt32(&violated), "OnTaskStart shouldn't come before OnSubmit")
+	stats := p.Stats()
+	assert.EqualValues(t, 96, stats.Submitted)
+	assert.EqualValues(t, 96, stats.Completed)
+}
+
+func TestPoolWithFuncOf(t *testing.T) {
+	var (
+		wg  sync.WaitGroup
+		sum int64
+	)
+	p, err := NewPoolWithFuncOf(10, func(n int64) {
+		atomic.AddInt64(&sum, n)
+		wg.Done()
+	}, WithExpiryDuration(100*time.Millisecond))
+	assert.NoErrorf(t, err, "create PoolWithFuncOf failed: %v", err)
+	defer p.Release()
+	for i := 0; i < 1000; i++ {
+		wg.Add(1)
+		assert.NoError(t, p.Invoke(int64(i)))
+	}
+	wg.Wait()
+	assert.EqualValues(t, 499500, atomic.LoadInt64(&sum))
+	time.Sleep(500 * time.Millisecond)
+	assert.EqualValues(t, 0, p.Running(), "all workers should be purged")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pc, err := NewPoolWithFuncOfContext(1, func(ctx context.Context, ch chan struct{}) {
+		<-ch
+	})
+	assert.NoErrorf(t, err, "create PoolWithFuncOf failed: %v", err)
+	defer pc.Release()
+	ch := make(chan struct{})
+	assert.NoError(t, pc.Invoke(ch))
+	cancel()
+	assert.Equal(t, context.Canceled, pc.InvokeContext(ctx, ch), "invoke should give up when ctx is done")
+	close(ch)
+
+	_, err = NewPoolWithFuncOf[int](1, nil)
+	assert.Equal(t, ErrLackPoolFunc, err)
+}
+
+func TestNilTask(t *testing.T) {
+	p, err := NewPool(1)
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	assert.NoError(t, p.Submit(wg.Done))
+	wg.Wait()
+	assert.Equal(t, ErrNilTask, p.Submit(nil), "nil task should be rejected")
+	assert.Equal(t, ErrNilTask, p.SubmitContext(context.Background(), nil), "nil task should be rejected")
+	_, err = SubmitFuture[int](p, nil)
+	assert.Equal(t, ErrNilTask, err, "nil task should be rejected")
+	assert.EqualValues(t, 1, p.Running(), "nil task shouldn't kill the worker")
+	wg.Add(1)
+	assert.NoError(t, p.Submit(wg.Done))
+	wg.Wait()
+	assert.EqualValues(t, 1, p.Stats().WorkersSpawned, "the idle worker should be reused")
+
+	args := make(chan interface{}, 2)
+	pf, err := NewPoolWithFunc(1, func(i interface{}) { args <- i })
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer pf.Release()
+	assert.NoError(t, pf.Invoke(nil), "nil should be a legal argument")
+	assert.Nil(t, <-args, "pool func should be called with nil")
+	assert.NoError(t, pf.Invoke(1))
+	assert.EqualValues(t, 1, <-args)
+	assert.EqualValues(t, 1, pf.Stats().WorkersSpawned, "nil argument shouldn't kill the worker")
+}
+
 func TestRebootDefaultPool(t *testing.T) {
 	defer Release()
 	Reboot()
@@ -535,6 +1137,497 @@ func TestRebootNewPool(t *testing.T) {
 	wg.Wait()
 }
 
+func TestRebootCycles(t *testing.T) {
+	for _, preAlloc := range []bool{false, true} {
+		p, err := NewPool(2, WithPreAlloc(preAlloc), WithExpiryDuration(100*time.Millisecond))
+		assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+		p1, err := NewPoolWithFunc(2, func(i interface{}) { i.(*sync.WaitGroup).Done() },
+			WithPreAlloc(preAlloc), WithExpiryDuration(100*time.Millisecond))
+		assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+		for cycle := 0; cycle < 3; cycle++ {
+			var wg sync.WaitGroup
+			// Run the tasks one by one so that a single worker is put back and reused every time.
+			for i := 0; i < 5; i++ {
+				wg.Add(2)
+				assert.NoError(t, p.Submit(wg.Done), "pool should accept tasks after rebooting")
+				assert.NoError(t, p1.Invoke(&wg), "pool should accept tasks after rebooting")
+				wg.Wait()
+				time.Sleep(time.Millisecond)
+			}
+			//PreAlloc的池子在创建以及Reboot时都会预先开启worker
+			workers := 1
+			if preAlloc {
+				workers = preAllocWorkers(2)
+			}
+			for _, stats := range []PoolStats{p.Stats(), p1.Stats()} {
+				assert.EqualValuesf(t, workers, stats.WorkersSpawned, "prealloc: %v, cycle %d: workers should be reused", preAlloc, cycle)
+				assert.EqualValuesf(t, 5, stats.Submitted, "prealloc: %v, cycle %d: stats should be reset", preAlloc, cycle)
+				assert.EqualValuesf(t, workers, stats.Idle, "prealloc: %v, cycle %d: worker should be idle", preAlloc, cycle)
+			}
+			time.Sleep(500 * time.Millisecond)
+			assert.EqualValuesf(t, 0, p.Running(), "prealloc: %v, cycle %d: idle workers should be purged", preAlloc, cycle)
+			assert.EqualValuesf(t, 0, p1.Running(), "prealloc: %v, cycle %d: idle workers should be purged", preAlloc, cycle)
+			p.Release()
+			p1.Release()
+			assert.Equal(t, ErrPoolClosed, p.Submit(demoFunc), "pool should be closed")
+			assert.Equal(t, ErrPoolClosed, p1.Invoke(&wg), "pool should be closed")
+			p.Reboot()
+			p1.Reboot()
+		}
+		p.Release()
+		p1.Release()
+	}
+}
+
+func TestTunePreAlloc(t *testing.T) {
+	p, err := NewPool(2, WithPreAlloc(true), WithNonblocking(true))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	p1, err := NewPoolWithFunc(2, longRunningPoolFunc, WithPreAlloc(true), WithNonblocking(true))
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer p1.Release()
+
+	ch := make(chan struct{})
+	p.Tune(4)
+	p1.Tune(4)
+	assert.EqualValues(t, 4, p.Cap())
+	assert.EqualValues(t, 4, p1.Cap())
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, p.Submit(func() { <-ch }), "pool should be grown")
+		assert.NoError(t, p1.Invoke(ch), "pool should be grown")
+	}
+	assert.Equal(t, ErrPoolOverload, p.Submit(demoFunc))
+	assert.Equal(t, ErrPoolOverload, p1.Invoke(ch))
+	close(ch)
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualValues(t, 4, p.Stats().Idle, "all workers should be put back into the grown queue")
+	assert.EqualValues(t, 4, p1.Stats().Idle, "all workers should be put back into the grown queue")
+
+	p.Tune(1)
+	p1.Tune(1)
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualValues(t, 1, p.Running(), "surplus idle workers should exit")
+	assert.EqualValues(t, 1, p1.Running(), "surplus idle workers should exit")
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		assert.NoError(t, p.Submit(wg.Done), "the remaining worker should be reused")
+		wg.Wait()
+		time.Sleep(time.Millisecond)
+	}
+	assert.EqualValues(t, 4, p.Stats().WorkersSpawned, "the remaining worker should be reused")
+}
+
+func TestTuneAppliesCapacity(t *testing.T) {
+	p, err := NewPool(1)
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	ch := make(chan struct{})
+	assert.NoError(t, p.Submit(func() { <-ch }))
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			_ = p.Submit(wg.Done)
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualValues(t, 2, p.Stats().Waiting, "submitters should be blocked")
+	assert.EqualValues(t, 1, p.Tune(3), "Tune should return the old capacity")
+	wg.Wait() // The blocked submitters should spawn workers after growing.
+
+	assert.EqualValues(t, 3, p.Tune(1), "Tune should return the old capacity")
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualValues(t, 1, p.Running(), "surplus idle workers should be retired at once")
+	close(ch)
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualValues(t, 1, p.Stats().Idle, "the running worker should be put back since it's within capacity")
+	assert.EqualValues(t, 1, p.Tune(-1), "invalid size should be ignored")
+	assert.EqualValues(t, 1, p.Cap())
+
+	block := &sync.WaitGroup{}
+	block.Add(1)
+	p2, err := NewPoolWithFunc(1, func(i interface{}) {
+		if i == nil {
+			block.Wait()
+			return
+		}
+		i.(*sync.WaitGroup).Done()
+	}, WithQueueSize(2))
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer p2.Release()
+	assert.NoError(t, p2.Invoke(nil))
+	wg.Add(2)
+	assert.NoError(t, p2.Invoke(&wg))
+	assert.NoError(t, p2.Invoke(&wg))
+	assert.EqualValues(t, 2, p2.QueueLen())
+	assert.EqualValues(t, 1, p2.Tune(3), "Tune should return the old capacity")
+	wg.Wait()
+	assert.EqualValues(t, 0, p2.QueueLen(), "queued invocations should run on new workers after growing")
+	block.Done()
+}
+
+func TestSubmitWithPriority(t *testing.T) {
+	p, err := NewPool(1)
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	block := make(chan struct{})
+	assert.NoError(t, p.Submit(func() { <-block }))
+
+	var (
+		mu    sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+	)
+	priorities := []int{0, 1, 5, 1, -2}
+	for i, priority := range priorities {
+		i, priority := i, priority
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = p.SubmitWithPriority(func() {
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+			}, priority)
+		}()
+		//等上一个提交者挂到等待队列中之后再提交下一个，保证先来后到的顺序
+		for p.Stats().Waiting != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	stats := p.Stats()
+	assert.EqualValues(t, 2, stats.Priorities[1].Waiting, "Priorities error")
+	assert.EqualValues(t, 1, stats.Priorities[5].Waiting, "Priorities error")
+	assert.EqualValues(t, 1, stats.Priorities[-2].Waiting, "Priorities error")
+	close(block)
+	wg.Wait()
+	for p.Stats().Completed != uint64(len(priorities)+1) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.EqualValues(t, []int{2, 1, 3, 0, 4}, order, "waiters should be served by priority, then FIFO")
+	stats = p.Stats()
+	assert.EqualValues(t, 2, stats.Priorities[0].Submitted, "Priorities error")
+	assert.EqualValues(t, 2, stats.Priorities[1].Submitted, "Priorities error")
+	assert.EqualValues(t, 1, stats.Priorities[5].Submitted, "Priorities error")
+	assert.EqualValues(t, 0, stats.Priorities[1].Waiting, "Priorities error")
+
+	args := make(chan int, len(priorities))
+	block = make(chan struct{})
+	pf, err := NewPoolWithFuncOf(1, func(i int) {
+		if i < 0 {
+			<-block
+			return
+		}
+		args <- i
+	}, WithQueueSize(len(priorities)))
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer pf.Release()
+	assert.NoError(t, pf.Invoke(-1))
+	for i, priority := range priorities {
+		assert.NoError(t, pf.InvokeWithPriority(i, priority))
+	}
+	assert.EqualValues(t, 1, pf.Stats().Priorities[-2].Waiting, "queued tasks should be counted as waiting")
+	close(block)
+	for _, want := range []int{2, 1, 3, 0, 4} {
+		assert.EqualValues(t, want, <-args, "queued tasks should be executed by priority, then FIFO")
+	}
+}
+
+func TestBlockedSubmitFIFO(t *testing.T) {
+	p, err := NewPool(1)
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	block := make(chan struct{})
+	assert.NoError(t, p.Submit(func() { <-block }))
+
+	const n = 20
+	var (
+		mu    sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+	)
+	record := func(i int) func() {
+		return func() {
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			wg.Done()
+		}
+	}
+	wg.Add(2 * n)
+	for i := 0; i < n; i++ {
+		go func(i int) { _ = p.Submit(record(i)) }(i)
+		for p.Stats().Waiting != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	close(block)
+	//排队期间新来的提交者不能插队
+	for i := n; i < 2*n; i++ {
+		go func(i int) { _ = p.Submit(record(i)) }(i)
+	}
+	wg.Wait()
+	for i := 0; i < n; i++ {
+		assert.EqualValues(t, i, order[i], "blocked submitters should acquire workers in arrival order")
+	}
+	assert.EqualValues(t, 1, p.Stats().WorkersSpawned, "the worker should be handed over directly")
+}
+
+func TestBlockedSubmitAfterPanic(t *testing.T) {
+	//清理间隔足够长，阻塞的提交者只能靠panic的worker退出时叫醒
+	p, err := NewPool(1, WithExpiryDuration(time.Minute), WithPanicHandler(func(interface{}) {}))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	block := make(chan struct{})
+	assert.NoError(t, p.Submit(func() {
+		<-block
+		panic("Oops!")
+	}))
+	done := make(chan struct{})
+	go func() { _ = p.Submit(func() { close(done) }) }()
+	for p.Stats().Waiting != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(block)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the blocked submitter should be woken up once the panicked worker exits")
+	}
+}
+
+func TestSubmitTimeout(t *testing.T) {
+	_, err := NewPool(1, WithMaxWaitDuration(-1))
+	assert.Equal(t, ErrInvalidMaxWaitDuration, err, "negative max wait duration should be rejected")
+
+	p, err := NewPool(1)
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	block := make(chan struct{})
+	assert.NoError(t, p.Submit(func() { <-block }))
+	start := time.Now()
+	assert.Equal(t, ErrSubmitTimeout, p.SubmitTimeout(demoFunc, 20*time.Millisecond))
+	assert.True(t, time.Since(start) >= 20*time.Millisecond, "should wait until timeout")
+	stats := p.Stats()
+	assert.EqualValues(t, 1, stats.TimedOut, "TimedOut error")
+	assert.EqualValues(t, 0, stats.Rejected, "timeout shouldn't be counted as rejected")
+	assert.EqualValues(t, 0, stats.Waiting, "timed out submitter should leave the wait queue")
+	close(block)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	assert.NoError(t, p.SubmitTimeout(wg.Done, 20*time.Millisecond))
+	wg.Wait()
+
+	block = make(chan struct{})
+	pf, err := NewPoolWithFuncOf(1, func(ch chan struct{}) { <-ch }, WithMaxWaitDuration(10*time.Millisecond))
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer pf.Release()
+	assert.NoError(t, pf.Invoke(block))
+	assert.Equal(t, ErrSubmitTimeout, pf.Invoke(block), "WithMaxWaitDuration should apply to Invoke")
+	//与单次指定的超时时间同时存在时以较短的为准
+	start = time.Now()
+	assert.Equal(t, ErrSubmitTimeout, pf.InvokeTimeout(block, time.Hour))
+	assert.True(t, time.Since(start) < time.Second, "the shorter timeout should take effect")
+	assert.EqualValues(t, 2, pf.Stats().TimedOut, "TimedOut error")
+	close(block)
+}
+
+func TestSubmitBatch(t *testing.T) {
+	p, err := NewPool(10, WithNonblocking(true))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	block := make(chan struct{})
+	tasks := make([]func(), 15)
+	for i := range tasks {
+		tasks[i] = func() { <-block }
+	}
+	n, err := p.SubmitBatch(append([]func(){nil}, tasks...))
+	assert.Equal(t, ErrNilTask, err, "nil task should be rejected")
+	assert.EqualValues(t, 0, n, "no task should be submitted with a nil task")
+	n, err = p.SubmitBatch(tasks)
+	assert.Equal(t, ErrPoolOverload, err, "the remainder should follow the nonblocking policy")
+	assert.EqualValues(t, 10, n, "SubmitBatch should fill the pool")
+	stats := p.Stats()
+	assert.EqualValues(t, 10, stats.Submitted, "Submitted error")
+	assert.EqualValues(t, 10, stats.Running, "Running error")
+	close(block)
+
+	//阻塞模式下剩下的任务等待空闲worker，全部都会被接受
+	p1, err := NewPool(10)
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p1.Release()
+	var wg sync.WaitGroup
+	tasks = make([]func(), 100)
+	for i := range tasks {
+		tasks[i] = func() {
+			demoFunc()
+			wg.Done()
+		}
+	}
+	wg.Add(len(tasks))
+	n, err = p1.SubmitBatch(tasks)
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(tasks), n, "all tasks should be accepted in blocking mode")
+	wg.Wait()
+	assert.EqualValues(t, 10, p1.Stats().WorkersSpawned, "SubmitBatch shouldn't exceed the capacity")
+
+	blockFunc := make(chan struct{})
+	pf, err := NewPoolWithFuncOf(2, func(ch chan struct{}) { <-ch }, WithQueueSize(3))
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer pf.Release()
+	args := make([]chan struct{}, 6)
+	for i := range args {
+		args[i] = blockFunc
+	}
+	n, err = pf.InvokeBatch(args)
+	assert.Equal(t, ErrPoolOverload, err, "the remainder should overflow the task queue")
+	assert.EqualValues(t, 5, n, "InvokeBatch should fill the pool and the task queue")
+	stats = pf.Stats()
+	assert.EqualValues(t, 2, stats.Running, "Running error")
+	assert.EqualValues(t, 3, stats.Queued, "Queued error")
+	close(blockFunc)
+}
+
+func TestPoolWait(t *testing.T) {
+	p, err := NewPool(5, WithQueueSize(11), WithPanicHandler(func(interface{}) {}))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	p.Wait() //没有任务时立即返回
+
+	var finished int32
+	for i := 0; i < 15; i++ {
+		assert.NoError(t, p.Submit(func() {
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&finished, 1)
+		}))
+	}
+	assert.NoError(t, p.Submit(func() { panic("oops") }))
+	p.Wait()
+	assert.EqualValues(t, 15, atomic.LoadInt32(&finished), "Wait should return after all tasks finished, including the queued ones")
+	assert.True(t, p.Running() > 0, "idle workers shouldn't be waited for")
+
+	block := make(chan struct{})
+	assert.NoError(t, p.Submit(func() { <-block }))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, p.WaitContext(ctx), "WaitContext should give up once ctx is done")
+	close(block)
+	assert.NoError(t, p.WaitContext(context.Background()))
+
+	var sum int32
+	pf, err := NewPoolWithFuncOf(5, func(i int32) {
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&sum, i)
+	})
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer pf.Release()
+	args := make([]int32, 20)
+	for i := range args {
+		args[i] = int32(i + 1)
+	}
+	n, err := pf.InvokeBatch(args)
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(args), n)
+	pf.Wait()
+	assert.EqualValues(t, 210, atomic.LoadInt32(&sum), "Wait should return after all tasks finished")
+}
+
+func TestBusyIdleAlive(t *testing.T) {
+	p, err := NewPool(10, WithExpiryDuration(time.Hour))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	block := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, p.Submit(func() { <-block }))
+	}
+	close(block)
+	for p.Idle() != 3 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.EqualValues(t, 0, p.Busy(), "Busy error")
+	block = make(chan struct{})
+	assert.NoError(t, p.Submit(func() { <-block }))
+	for p.Busy() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.EqualValues(t, 3, p.Alive(), "idle workers should be alive")
+	assert.EqualValues(t, p.Running(), p.Alive(), "Running should be the same as Alive")
+	assert.EqualValues(t, 2, p.Idle(), "Idle error")
+	assert.EqualValues(t, 9, p.Free(), "idle workers shouldn't take up the capacity")
+	assert.EqualValues(t, 1, p.Stats().Busy, "Busy error")
+	close(block)
+	p.Wait()
+	assert.EqualValues(t, 0, p.Busy(), "Busy error")
+	assert.EqualValues(t, 10, p.Free(), "Free error")
+
+	block = make(chan struct{})
+	pf, err := NewPoolWithFuncOf(10, func(ch chan struct{}) { <-ch })
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer pf.Release()
+	assert.NoError(t, pf.Invoke(block))
+	for pf.Busy() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.EqualValues(t, 1, pf.Alive(), "Alive error")
+	assert.EqualValues(t, 0, pf.Idle(), "Idle error")
+	assert.EqualValues(t, 9, pf.Free(), "Free error")
+	close(block)
+	for pf.Idle() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.EqualValues(t, 10, pf.Free(), "Free error")
+}
+
+func TestMinIdleWorkers(t *testing.T) {
+	_, err := NewPool(10, WithMinIdleWorkers(-1))
+	assert.Equal(t, ErrInvalidMinIdleWorkers, err, "negative min idle workers should be rejected")
+
+	p, err := NewPool(10, WithExpiryDuration(20*time.Millisecond), WithMinIdleWorkers(3))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	assert.EqualValues(t, 0, p.Running(), "workers shouldn't be warmed up without PreAlloc")
+	block := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, p.Submit(func() { <-block }))
+	}
+	close(block)
+	p.Wait()
+	time.Sleep(10 * 20 * time.Millisecond)
+	assert.EqualValues(t, 3, p.Running(), "min idle workers should survive purges")
+	assert.EqualValues(t, 3, p.Idle(), "min idle workers should survive purges")
+	assert.EqualValues(t, 7, p.Stats().WorkersPurged, "the rest should be purged")
+
+	assert.EqualValues(t, 7, p.Warmup(20), "Warmup should be limited by the capacity")
+	assert.EqualValues(t, 10, p.Idle(), "warmed up workers should be idle")
+	assert.EqualValues(t, 0, p.Warmup(1), "Warmup shouldn't exceed the capacity")
+
+	//只开启PreAlloc的话预先开启GOMAXPROCS个worker，空闲过期之后依旧会被清理
+	pp, err := NewPool(10, WithPreAlloc(true), WithExpiryDuration(20*time.Millisecond))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer pp.Release()
+	assert.EqualValues(t, preAllocWorkers(10), pp.Running(), "PreAlloc should warm up workers")
+	assert.EqualValues(t, preAllocWorkers(10), pp.Idle(), "PreAlloc should warm up workers")
+	time.Sleep(10 * 20 * time.Millisecond)
+	assert.EqualValues(t, 0, pp.Running(), "warmed up workers should be purged without min idle workers")
+
+	pf, err := NewPoolWithFuncOf(10, func(int) {}, WithPreAlloc(true), WithMinIdleWorkers(4))
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer pf.Release()
+	assert.EqualValues(t, 4, pf.Running(), "PreAlloc should warm up min idle workers")
+	assert.EqualValues(t, 4, pf.Idle(), "PreAlloc should warm up min idle workers")
+	assert.NoError(t, pf.Invoke(1))
+	pf.Wait()
+	assert.EqualValues(t, 4, pf.Stats().WorkersSpawned, "warmed up workers should be reused")
+	pf.Release()
+	assert.EqualValues(t, 0, pf.Warmup(1), "Warmup shouldn't spawn workers on a closed pool")
+	for pf.Running() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	pf.Reboot()
+	assert.EqualValues(t, 4, pf.Idle(), "Reboot should warm up min idle workers again")
+}
+
 func TestRestCodeCoverage(t *testing.T) {
 	_, err := NewPool(-1, WithExpiryDuration(-1))
 	t.Log(err)
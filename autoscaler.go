@@ -0,0 +1,177 @@
+package ants
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+//默认的扩缩容周期
+const defaultAutoscaleInterval = time.Second
+
+//ScalingMetrics是自动扩缩容时在一个周期内观测到的池子状态，耗时都是这个周期内的平均值
+type ScalingMetrics struct {
+	Capacity     int           //当前的容量
+	Running      int           //当前运行的worker数量
+	Idle         int           //空闲的worker数量，Running - Idle即正在执行任务的worker数量
+	Waiting      int           //阻塞等待空闲worker的提交者数量
+	Queued       int           //任务队列中等待执行的任务数量
+	Started      uint64        //这个周期内开始执行的任务数量
+	Completed    uint64        //这个周期内正常执行结束的任务数量
+	WaitDuration time.Duration //任务从提交到开始执行的平均等待时间
+	TaskDuration time.Duration //任务的平均执行耗时
+}
+
+//ScalingPolicy根据观测到的状态计算池子新的容量，返回值会被限制在AutoscalerConfig的[Min, Max]之间
+type ScalingPolicy interface {
+	NextCapacity(m ScalingMetrics) int
+}
+
+//AutoscalerConfig是自动扩缩容的配置
+type AutoscalerConfig struct {
+	Min      int           //容量的下限，必须大于0
+	Max      int           //容量的上限，不能小于Min
+	Interval time.Duration //扩缩容的周期，0表示使用默认的1s
+	Policy   ScalingPolicy //扩缩容策略，nil表示使用AIMDPolicy{}
+}
+
+//AIMDPolicy是加性增、乘性减的策略，与TCP的拥塞控制类似：
+//有提交者在等待或者有任务积压时容量增加Increase，正在执行任务的worker不到容量的一半时容量乘以Decrease，其他情况保持不变
+type AIMDPolicy struct {
+	Increase int     //每次增加的容量，0表示1
+	Decrease float64 //缩容时的系数，取值(0, 1)，0表示0.9
+}
+
+func (a AIMDPolicy) NextCapacity(m ScalingMetrics) int {
+	increase, decrease := a.Increase, a.Decrease
+	if increase <= 0 {
+		increase = 1
+	}
+	if decrease <= 0 || decrease >= 1 {
+		decrease = 0.9
+	}
+	switch {
+	case m.Waiting > 0 || m.Queued > 0:
+		return m.Capacity + increase
+	case (m.Running-m.Idle)*2 < m.Capacity:
+		return int(float64(m.Capacity) * decrease)
+	default:
+		return m.Capacity
+	}
+}
+
+//GradientPolicy以任务的平均等待时间为目标来调整容量：新容量 = 容量 * 平均等待时间 / Target，
+//即等待太久时按比例扩容，等待时间低于目标并且还有空闲的容量时按比例缩容，每次的调整幅度在[1/2, 2]倍之间
+type GradientPolicy struct {
+	Target time.Duration //任务从提交到开始执行的目标等待时间，0表示1ms
+}
+
+func (g GradientPolicy) NextCapacity(m ScalingMetrics) int {
+	target := g.Target
+	if target <= 0 {
+		target = time.Millisecond
+	}
+	if m.Started == 0 {
+		//这个周期内没有任务开始执行，积压着任务说明worker都被卡住了，只能先扩容
+		if m.Waiting > 0 || m.Queued > 0 {
+			return m.Capacity * 2
+		}
+		return m.Capacity
+	}
+	gradient := math.Max(0.5, math.Min(2, float64(m.WaitDuration)/float64(target)))
+	if gradient < 1 && m.Running-m.Idle >= m.Capacity {
+		return m.Capacity
+	}
+	return int(math.Ceil(float64(m.Capacity) * gradient))
+}
+
+//设置自动扩缩容，池子会定期根据Policy调整自己的容量
+func WithAutoscaler(config AutoscalerConfig) Option {
+	return func(opts *Options) {
+		opts.Autoscaler = &config
+	}
+}
+
+//检查自动扩缩容的配置并设置默认值
+func (c *AutoscalerConfig) validate() error {
+	if c.Min <= 0 || c.Max < c.Min || c.Interval < 0 {
+		return ErrInvalidAutoscaler
+	}
+	if c.Interval == 0 {
+		c.Interval = defaultAutoscaleInterval
+	}
+	if c.Policy == nil {
+		c.Policy = AIMDPolicy{}
+	}
+	return nil
+}
+
+//scalable是可以自动扩缩容的池子，Pool与PoolWithFuncOf都实现了它
+type scalable interface {
+	Stats() PoolStats
+	Tune(size int) int
+}
+
+//定期观测池子的状态并调整容量，直到ctx结束(即池子被关闭)，Reboot清零统计数据之后会重新开启，所以两次快照之间不会跨过Reboot
+func autoscale(ctx context.Context, p scalable, config *AutoscalerConfig, stats *poolStats, logger Logger) {
+	heartbeat := time.NewTicker(config.Interval)
+	defer heartbeat.Stop()
+
+	prev := p.Stats()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+		}
+		cur := p.Stats()
+		m := scalingMetrics(&prev, &cur)
+		prev = cur
+
+		size := config.Policy.NextCapacity(m)
+		if size < config.Min {
+			size = config.Min
+		} else if size > config.Max {
+			size = config.Max
+		}
+		if size == m.Capacity {
+			continue
+		}
+		if size > m.Capacity {
+			atomic.AddUint64(&stats.scaleUps, 1)
+		} else {
+			atomic.AddUint64(&stats.scaleDowns, 1)
+		}
+		p.Tune(size)
+		logger.Printf("autoscaler: capacity %d -> %d (running: %d, waiting: %d, queued: %d, wait: %v, latency: %v)\n",
+			m.Capacity, size, m.Running, m.Waiting, m.Queued, m.WaitDuration, m.TaskDuration)
+	}
+}
+
+//根据两次统计快照计算这个周期内的观测值
+func scalingMetrics(prev, cur *PoolStats) ScalingMetrics {
+	m := ScalingMetrics{
+		Capacity: cur.Capacity,
+		Running:  cur.Running,
+		Idle:     cur.Idle,
+		Waiting:  cur.Waiting,
+		Queued:   cur.Queued,
+	}
+	m.Started = histogramCount(cur.WaitDurations) - histogramCount(prev.WaitDurations)
+	m.Completed = cur.Completed - prev.Completed
+	if m.Started > 0 {
+		m.WaitDuration = (cur.WaitDuration - prev.WaitDuration) / time.Duration(m.Started)
+	}
+	if m.Completed > 0 {
+		m.TaskDuration = (cur.TaskDuration - prev.TaskDuration) / time.Duration(m.Completed)
+	}
+	return m
+}
+
+func histogramCount(h DurationHistogram) (n uint64) {
+	for _, c := range h.Counts {
+		n += c
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package ants
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAIMDPolicy(t *testing.T) {
+	p := AIMDPolicy{}
+	assert.EqualValues(t, 11, p.NextCapacity(ScalingMetrics{Capacity: 10, Running: 10, Waiting: 1}))
+	assert.EqualValues(t, 11, p.NextCapacity(ScalingMetrics{Capacity: 10, Running: 10, Queued: 1}))
+	assert.EqualValues(t, 10, p.NextCapacity(ScalingMetrics{Capacity: 10, Running: 6}))
+	assert.EqualValues(t, 9, p.NextCapacity(ScalingMetrics{Capacity: 10, Running: 4}))
+	assert.EqualValues(t, 9, p.NextCapacity(ScalingMetrics{Capacity: 10, Running: 10, Idle: 6}), "idle workers should be excluded")
+
+	p = AIMDPolicy{Increase: 5, Decrease: 0.5}
+	assert.EqualValues(t, 15, p.NextCapacity(ScalingMetrics{Capacity: 10, Running: 10, Waiting: 1}))
+	assert.EqualValues(t, 5, p.NextCapacity(ScalingMetrics{Capacity: 10, Running: 1}))
+}
+
+func TestGradientPolicy(t *testing.T) {
+	p := GradientPolicy{Target: 10 * time.Millisecond}
+	m := ScalingMetrics{Capacity: 10, Running: 10, Started: 100, WaitDuration: 15 * time.Millisecond}
+	assert.EqualValues(t, 15, p.NextCapacity(m), "should grow in proportion to the wait time")
+	m.WaitDuration = time.Second
+	assert.EqualValues(t, 20, p.NextCapacity(m), "should grow at most twice")
+	m.WaitDuration = time.Millisecond
+	assert.EqualValues(t, 10, p.NextCapacity(m), "shouldn't shrink when all workers are busy")
+	m.Running = 3
+	assert.EqualValues(t, 5, p.NextCapacity(m), "should shrink at most by half")
+	m.WaitDuration = 8 * time.Millisecond
+	assert.EqualValues(t, 8, p.NextCapacity(m), "should shrink in proportion to the wait time")
+	assert.EqualValues(t, 20, p.NextCapacity(ScalingMetrics{Capacity: 10, Running: 10, Waiting: 1}),
+		"should grow when workers are stuck")
+	assert.EqualValues(t, 10, p.NextCapacity(ScalingMetrics{Capacity: 10}))
+}
+
+func TestAutoscaler(t *testing.T) {
+	_, err := NewPool(1, WithAutoscaler(AutoscalerConfig{Min: 2, Max: 1}))
+	assert.Equal(t, ErrInvalidAutoscaler, err)
+	_, err = NewPoolWithFunc(1, demoPoolFunc, WithAutoscaler(AutoscalerConfig{}))
+	assert.Equal(t, ErrInvalidAutoscaler, err)
+
+	config := AutoscalerConfig{Min: 1, Max: 4, Interval: 20 * time.Millisecond}
+	p, err := NewPool(1, WithAutoscaler(config))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	assert.Nil(t, config.Policy, "the caller's config shouldn't be modified")
+
+	ch := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			_ = p.Submit(func() { <-ch })
+			wg.Done()
+		}()
+	}
+	time.Sleep(300 * time.Millisecond)
+	assert.EqualValues(t, 4, p.Cap(), "pool should be scaled up to Max")
+	assert.EqualValues(t, 4, p.Running())
+	close(ch)
+	wg.Wait()
+	time.Sleep(500 * time.Millisecond)
+	assert.EqualValues(t, 1, p.Cap(), "pool should be scaled down to Min")
+	stats := p.Stats()
+	assert.EqualValues(t, 3, stats.ScaleUps)
+	assert.True(t, stats.ScaleDowns > 0, "scaling down should be counted")
+}
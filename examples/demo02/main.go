@@ -1,11 +1,9 @@
-
-
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/panjf2000/ants/v2"
-	"sync"
 	"time"
 )
 
@@ -17,27 +15,29 @@ func demoFunc() {
 //创建池子的时候不指明回调
 //@todo pool, _ := ants.NewPool()
 //@todo defer pool.Release()
-//@todo pool.Invoke()
+//@todo pool.Group(ctx).Go()
 func main() {
 	//初始化变量
 	runTimes := 1000
-	var wg sync.WaitGroup
 	//创建一个池子(未指明任何回到方法)
 	pool, _ := ants.NewPool(10)
 	defer pool.Release()
+	//创建一个任务组，不用再自己维护sync.WaitGroup了
+	group := pool.Group(context.Background())
 
 	//任务回调函数
-	syncCalculateSum := func() {
+	syncCalculateSum := func(ctx context.Context) error {
 		demoFunc()
-		wg.Done()
+		return nil
 	}
 	//循环提交任务
 	for i := 0; i < runTimes; i++ {
-		wg.Add(1)
-		pool.Submit(syncCalculateSum)
+		_ = group.Go(syncCalculateSum)
 	}
 	//等待
-	wg.Wait()
+	if err := group.Wait(); err != nil {
+		fmt.Printf("task failed: %v\n", err)
+	}
 	fmt.Printf("running goroutines: %d\n", pool.Running())
 	fmt.Printf("finish all tasks.\n")
 }
@@ -11,9 +11,7 @@ import (
 
 var sum int32
 
-func myFunc(i interface{}) {
-	//将interface类型转成int32
-	n := i.(int32)
+func myFunc(n int32) {
 	//追加到sum变量中
 	atomic.AddInt32(&sum, n)
 	fmt.Printf("run with %d\n", n)
@@ -29,7 +27,8 @@ func main() {
 	var wg sync.WaitGroup
 	//一个执行批量同类任务的协程池，PoolWithFunc相较于Pool，因为一个池只绑定一个任务函数，
 	//省去了每一次task都需要传送一个任务函数的代价，因此其性能优势比起Pool更明显
-	pool, _ := ants.NewPoolWithFunc(10, func(i interface{}) {
+	//通过NewPoolWithFuncOf指明参数的类型，省去了类型断言以及参数装箱到interface的开销
+	pool, _ := ants.NewPoolWithFuncOf(10, func(i int32) {
 		myFunc(i)
 		wg.Done()
 	})
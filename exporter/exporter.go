@@ -0,0 +1,184 @@
+//Package exporter以Prometheus文本格式导出ants池子的运行状态，
+//没有依赖Prometheus的客户端库，以保持ants模块足够轻量。
+//
+//	e := exporter.New()
+//	_ = e.Register("api", pool)
+//	http.Handle("/metrics", e)
+package exporter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/panjf2000/ants/v2"
+)
+
+var (
+	ErrInvalidName   = errors.New("pool name must not be empty")
+	ErrDuplicateName = errors.New("pool name has already been registered")
+)
+
+//Source是可以被导出的池子，*ants.Pool与*ants.PoolWithFunc都实现了该接口
+type Source interface {
+	Stats() ants.PoolStats
+}
+
+//Exporter导出所有注册了的池子的指标，每个池子通过名为pool的标签区分，它实现了http.Handler
+type Exporter struct {
+	mu    sync.RWMutex
+	pools map[string]Source
+}
+
+//创建一个Exporter
+func New() *Exporter {
+	return &Exporter{pools: make(map[string]Source)}
+}
+
+//以name为名注册一个池子
+func (e *Exporter) Register(name string, pool Source) error {
+	if name == "" {
+		return ErrInvalidName
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.pools[name]; ok {
+		return ErrDuplicateName
+	}
+	e.pools[name] = pool
+	return nil
+}
+
+//注销名为name的池子
+func (e *Exporter) Unregister(name string) {
+	e.mu.Lock()
+	delete(e.pools, name)
+	e.mu.Unlock()
+}
+
+//以Prometheus文本格式输出指标
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = e.Write(w)
+}
+
+//sample是某个池子的统计快照
+type sample struct {
+	name  string
+	stats ants.PoolStats
+}
+
+//metric描述一个指标以及如何从统计快照中取值
+type metric struct {
+	name  string
+	help  string
+	typ   string
+	value func(s *ants.PoolStats) float64
+}
+
+var metrics = []metric{
+	{"ants_pool_capacity", "Capacity of the pool.", "gauge",
+		func(s *ants.PoolStats) float64 { return float64(s.Capacity) }},
+	{"ants_pool_concurrency_limit", "Number of tasks allowed to run concurrently.", "gauge",
+		func(s *ants.PoolStats) float64 { return float64(s.Limit) }},
+	{"ants_pool_running_workers", "Number of running workers.", "gauge",
+		func(s *ants.PoolStats) float64 { return float64(s.Running) }},
+	{"ants_pool_busy_workers", "Number of workers running tasks.", "gauge",
+		func(s *ants.PoolStats) float64 { return float64(s.Busy) }},
+	{"ants_pool_idle_workers", "Number of idle workers.", "gauge",
+		func(s *ants.PoolStats) float64 { return float64(s.Idle) }},
+	{"ants_pool_waiting_submitters", "Number of submitters blocked waiting for a worker.", "gauge",
+		func(s *ants.PoolStats) float64 { return float64(s.Waiting) }},
+	{"ants_pool_queued_tasks", "Number of tasks waiting in the task queue.", "gauge",
+		func(s *ants.PoolStats) float64 { return float64(s.Queued) }},
+	{"ants_pool_submitted_tasks_total", "Total number of tasks accepted by the pool.", "counter",
+		func(s *ants.PoolStats) float64 { return float64(s.Submitted) }},
+	{"ants_pool_completed_tasks_total", "Total number of tasks completed.", "counter",
+		func(s *ants.PoolStats) float64 { return float64(s.Completed) }},
+	{"ants_pool_rejected_tasks_total", "Total number of tasks rejected when the pool was saturated.", "counter",
+		func(s *ants.PoolStats) float64 { return float64(s.Rejected) }},
+	{"ants_pool_discarded_tasks_total", "Total number of tasks discarded by the rejection policy.", "counter",
+		func(s *ants.PoolStats) float64 { return float64(s.Discarded) }},
+	{"ants_pool_timed_out_tasks_total", "Total number of submissions that timed out waiting for an idle worker.", "counter",
+		func(s *ants.PoolStats) float64 { return float64(s.TimedOut) }},
+	{"ants_pool_panics_total", "Total number of tasks that panicked.", "counter",
+		func(s *ants.PoolStats) float64 { return float64(s.Panicked) }},
+	{"ants_pool_spawned_workers_total", "Total number of workers spawned.", "counter",
+		func(s *ants.PoolStats) float64 { return float64(s.WorkersSpawned) }},
+	{"ants_pool_purged_workers_total", "Total number of idle workers purged after expiry.", "counter",
+		func(s *ants.PoolStats) float64 { return float64(s.WorkersPurged) }},
+	{"ants_pool_scale_ups_total", "Total number of times the autoscaler grew the pool.", "counter",
+		func(s *ants.PoolStats) float64 { return float64(s.ScaleUps) }},
+	{"ants_pool_scale_downs_total", "Total number of times the autoscaler shrank the pool.", "counter",
+		func(s *ants.PoolStats) float64 { return float64(s.ScaleDowns) }},
+}
+
+//histogram描述一个耗时分布的指标
+type histogram struct {
+	name string
+	help string
+	hist func(s *ants.PoolStats) (ants.DurationHistogram, time.Duration)
+}
+
+var histograms = []histogram{
+	{"ants_pool_task_duration_seconds", "Time spent running tasks.",
+		func(s *ants.PoolStats) (ants.DurationHistogram, time.Duration) { return s.TaskDurations, s.TaskDuration }},
+	{"ants_pool_queue_wait_seconds", "Time tasks spent waiting between submission and execution.",
+		func(s *ants.PoolStats) (ants.DurationHistogram, time.Duration) { return s.WaitDurations, s.WaitDuration }},
+}
+
+//将所有池子的指标以Prometheus文本格式写入w
+func (e *Exporter) Write(w io.Writer) error {
+	e.mu.RLock()
+	samples := make([]sample, 0, len(e.pools))
+	for name, pool := range e.pools {
+		samples = append(samples, sample{name: name, stats: pool.Stats()})
+	}
+	e.mu.RUnlock()
+	sort.Slice(samples, func(i, j int) bool { return samples[i].name < samples[j].name })
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+		for i := range samples {
+			fmt.Fprintf(bw, "%s{pool=\"%s\"} %s\n", m.name, escape(samples[i].name), formatFloat(m.value(&samples[i].stats)))
+		}
+	}
+	for _, h := range histograms {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+		for i := range samples {
+			name := escape(samples[i].name)
+			hist, sum := h.hist(&samples[i].stats)
+			var count uint64
+			for j, c := range hist.Counts {
+				count += c
+				le := "+Inf"
+				if j < len(hist.Buckets) {
+					le = formatFloat(hist.Buckets[j].Seconds())
+				}
+				fmt.Fprintf(bw, "%s_bucket{pool=\"%s\",le=\"%s\"} %d\n", h.name, name, le, count)
+			}
+			fmt.Fprintf(bw, "%s_sum{pool=\"%s\"} %s\n", h.name, name, formatFloat(sum.Seconds()))
+			fmt.Fprintf(bw, "%s_count{pool=\"%s\"} %d\n", h.name, name, count)
+		}
+	}
+	return bw.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+//转义标签值中的特殊字符
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,52 @@
+package exporter
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/panjf2000/ants/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExporter(t *testing.T) {
+	p, _ := ants.NewPool(2)
+	defer p.Release()
+	pf, _ := ants.NewPoolWithFunc(3, func(interface{}) {})
+	defer pf.Release()
+
+	e := New()
+	assert.NoError(t, e.Register("api", p))
+	assert.NoError(t, e.Register(`we"ird`, pf))
+	assert.Equal(t, ErrDuplicateName, e.Register("api", p))
+	assert.Equal(t, ErrInvalidName, e.Register("", p))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		_ = p.Submit(wg.Done)
+	}
+	wg.Wait()
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	body, _ := ioutil.ReadAll(rec.Body)
+	out := string(body)
+
+	assert.Contains(t, out, "# TYPE ants_pool_capacity gauge\n")
+	assert.Contains(t, out, `ants_pool_capacity{pool="api"} 2`+"\n")
+	assert.Contains(t, out, `ants_pool_capacity{pool="we\"ird"} 3`+"\n")
+	assert.Contains(t, out, `ants_pool_submitted_tasks_total{pool="api"} 5`+"\n")
+	assert.Contains(t, out, "# TYPE ants_pool_task_duration_seconds histogram\n")
+	assert.Contains(t, out, `ants_pool_queue_wait_seconds_bucket{pool="api",le="+Inf"} 5`+"\n")
+	assert.Contains(t, out, `ants_pool_queue_wait_seconds_count{pool="api"} 5`+"\n")
+	assert.Contains(t, out, `ants_pool_task_duration_seconds_bucket{pool="api",le="0.0001"}`)
+
+	e.Unregister("api")
+	var sb strings.Builder
+	assert.NoError(t, e.Write(&sb))
+	assert.NotContains(t, sb.String(), `pool="api"`)
+}
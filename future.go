@@ -0,0 +1,103 @@
+package ants
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+//Future表示一个已经提交到池子中的任务的执行结果，任务结束之后可以通过Get/Wait拿到它的返回值与错误，
+//省去了调用方自己用闭包+通道去收集结果的麻烦
+type Future[T any] struct {
+	done  chan struct{} //任务结束(包括panic)时关闭
+	once  sync.Once     //只有第一次结束有效，比如交给拒绝处理函数之后又被它执行了
+	value T
+	err   error
+}
+
+//Done返回一个在任务结束时被关闭的通道，方便与select配合使用
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+//Get阻塞直到任务结束，返回任务的结果
+func (f *Future[T]) Get() (T, error) {
+	<-f.done
+	return f.value, f.err
+}
+
+//Wait与Get一样等待任务结束，不同的是ctx结束时会放弃等待并返回ctx.Err()，此时任务依旧会继续执行
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+//PanicError是任务发生panic时Future返回的错误，Value是recover()得到的值，Stack是发生panic时的调用栈
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v", e.Value)
+}
+
+//根据recover()得到的值创建PanicError，必须在recover的defer函数中调用，以便拿到发生panic时的调用栈
+func newPanicError(r interface{}) *PanicError {
+	var buf [4096]byte
+	n := runtime.Stack(buf[:], false)
+	return &PanicError{Value: r, Stack: append([]byte(nil), buf[:n]...)}
+}
+
+//提交一个有返回值的任务到池子中，并返回该任务对应的Future
+//任务中发生的panic会以*PanicError的形式出现在Future上，之后依旧交由worker原有的恢复逻辑处理(PanicHandler或者日志)
+//被DiscardPolicy或者DiscardOldestPolicy丢弃的任务，Future会以ErrTaskDiscarded结束；
+//交给了自定义的拒绝处理函数的任务，Future会以ErrPoolOverload结束，之后拒绝处理函数即使执行了它，结果也不会再记录
+//由于go的方法不支持类型参数，所以这里只能是一个函数而不是Pool的方法
+func SubmitFuture[T any](p *Pool, task func() (T, error)) (*Future[T], error) {
+	if task == nil {
+		return nil, ErrNilTask
+	}
+	f := newFuture[T]()
+	//被拒绝策略丢弃时以ErrTaskDiscarded结束，否则等待它的调用方会一直阻塞下去
+	pt := poolTask{fn: func() { f.run(task) }, onDrop: f.fail}
+	if err := p.submit(context.Background(), pt, 0); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+//以value与err结束，只有第一次调用有效
+func (f *Future[T]) complete(value T, err error) {
+	f.once.Do(func() {
+		f.value, f.err = value, err
+		close(f.done)
+	})
+}
+
+//任务最终没有被池子执行，直接以err结束
+func (f *Future[T]) fail(err error) {
+	var zero T
+	f.complete(zero, err)
+}
+
+//执行task并将结果记录到Future上，task发生panic时先记录*PanicError，再继续panic交给worker处理
+func (f *Future[T]) run(task func() (T, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			f.fail(newPanicError(r))
+			panic(r)
+		}
+	}()
+	f.complete(task())
+}
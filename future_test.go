@@ -0,0 +1,223 @@
+package ants
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmitFuture(t *testing.T) {
+	p, err := NewPool(10)
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+
+	f, err := SubmitFuture(p, func() (int, error) {
+		return 42, nil
+	})
+	assert.NoError(t, err, "submit future shouldn't return error")
+	v, err := f.Get()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 42, v, "future should return the result of task")
+	select {
+	case <-f.Done():
+	default:
+		t.Fatal("done channel should be closed after task finished")
+	}
+
+	errOops := errors.New("oops")
+	fe, err := SubmitFuture(p, func() (string, error) {
+		return "", errOops
+	})
+	assert.NoError(t, err, "submit future shouldn't return error")
+	_, err = fe.Get()
+	assert.Equal(t, errOops, err, "future should return the error of task")
+
+	ch := make(chan struct{})
+	fw, err := SubmitFuture(p, func() (int, error) {
+		<-ch
+		return 1, nil
+	})
+	assert.NoError(t, err, "submit future shouldn't return error")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = fw.Wait(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err, "wait should give up when ctx is done")
+	close(ch)
+	v, err = fw.Wait(context.Background())
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, v, "future should return the result of task")
+
+	p.Release()
+	_, err = SubmitFuture(p, func() (int, error) { return 0, nil })
+	assert.Equal(t, ErrPoolClosed, err, "submit future to a closed pool should fail")
+}
+
+func TestSubmitFuturePanic(t *testing.T) {
+	var wg sync.WaitGroup
+	var handled interface{}
+	p, err := NewPool(10, WithPanicHandler(func(p interface{}) {
+		handled = p
+		wg.Done()
+	}))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+
+	wg.Add(1)
+	f, err := SubmitFuture(p, func() (int, error) {
+		panic("Oops!")
+	})
+	assert.NoError(t, err, "submit future shouldn't return error")
+	_, err = f.Get()
+	var pe *PanicError
+	assert.True(t, errors.As(err, &pe), "future should return a PanicError when task panics")
+	assert.EqualValues(t, "Oops!", pe.Value)
+	assert.NotEmpty(t, pe.Stack, "panic stack should be recorded")
+	wg.Wait()
+	assert.EqualValues(t, "Oops!", handled, "panic handler should still be called")
+}
+
+func TestSubmitFutureDiscarded(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	blockFunc := func() (int, error) {
+		<-block
+		return 0, nil
+	}
+
+	//队列满了之后，最老的排队任务被挤掉，它的Future以ErrTaskDiscarded结束
+	p, err := NewPool(1, WithQueueSize(1), WithRejectionPolicy(DiscardOldestPolicy))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	_, err = SubmitFuture(p, blockFunc)
+	assert.NoError(t, err, "submit future shouldn't return error")
+	oldest, err := SubmitFuture(p, blockFunc)
+	assert.NoError(t, err, "submit future shouldn't return error")
+	_, err = SubmitFuture(p, blockFunc)
+	assert.NoError(t, err, "DiscardOldestPolicy shouldn't return error")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = oldest.Wait(ctx)
+	assert.Equal(t, ErrTaskDiscarded, err, "the future of the discarded task should complete")
+
+	//DiscardPolicy丢弃的是新任务本身
+	p, err = NewPool(1, WithNonblocking(true), WithRejectionPolicy(DiscardPolicy))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	_, err = SubmitFuture(p, blockFunc)
+	assert.NoError(t, err, "submit future shouldn't return error")
+	f, err := SubmitFuture(p, blockFunc)
+	assert.NoError(t, err, "DiscardPolicy shouldn't return error")
+	_, err = f.Wait(ctx)
+	assert.Equal(t, ErrTaskDiscarded, err, "the future of the discarded task should complete")
+	assert.EqualValues(t, 1, p.Discarded(), "discarded task should be counted")
+
+	//交给拒绝处理函数的任务，Future以ErrPoolOverload结束，事后被拒绝处理函数执行了也不会改变结果
+	var rejected func()
+	p, err = NewPool(1, WithNonblocking(true), WithRejectionHandler(func(task interface{}) {
+		rejected = task.(func())
+	}))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	_, err = SubmitFuture(p, blockFunc)
+	assert.NoError(t, err, "submit future shouldn't return error")
+	f, err = SubmitFuture(p, func() (int, error) { return 1, nil })
+	assert.NoError(t, err, "rejection handler shouldn't return error")
+	_, err = f.Wait(ctx)
+	assert.Equal(t, ErrPoolOverload, err, "the future of the rejected task should complete")
+	rejected()
+	v, err := f.Get()
+	assert.Equal(t, ErrPoolOverload, err, "the future should keep the first result")
+	assert.EqualValues(t, 0, v)
+}
+
+func TestPoolWithFuncResult(t *testing.T) {
+	var wg sync.WaitGroup
+	p, err := NewPoolWithFuncResult(2, func(ctx context.Context, n int) (string, error) {
+		if n < 0 {
+			panic("negative")
+		}
+		return strings.Repeat("a", n), ctx.Err()
+	}, WithPanicHandler(func(interface{}) { wg.Done() }))
+	assert.NoErrorf(t, err, "create PoolWithFuncResult failed: %v", err)
+	defer p.Release()
+
+	fs := make([]*Future[string], 5)
+	for i := range fs {
+		fs[i], err = p.Invoke(i)
+		assert.NoError(t, err, "invoke shouldn't return error")
+	}
+	for i, f := range fs {
+		v, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, strings.Repeat("a", i), v, "future should return the result of pool func")
+	}
+	assert.EqualValues(t, 2, p.Cap())
+	assert.EqualValues(t, 5, p.Stats().Submitted)
+
+	wg.Add(1)
+	f, err := p.Invoke(-1)
+	assert.NoError(t, err, "invoke shouldn't return error")
+	_, err = f.Get()
+	_, ok := err.(*PanicError)
+	assert.True(t, ok, "future should return a *PanicError when pool func panics")
+	wg.Wait()
+
+	p.Release()
+	_, err = p.Invoke(1)
+	assert.Equal(t, ErrPoolClosed, err, "invoke on a closed pool should fail")
+}
+
+func TestPoolWithFuncResultRejected(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	pf := func(_ context.Context, n int) (int, error) {
+		<-block
+		return n, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	//被DiscardOldestPolicy挤掉的调用，Future以ErrTaskDiscarded结束，钩子收到的是Invoke的参数
+	var (
+		mu        sync.Mutex
+		submitted []interface{}
+	)
+	p, err := NewPoolWithFuncResult(1, pf, WithQueueSize(1), WithRejectionPolicy(DiscardOldestPolicy),
+		WithHooks(Hooks{OnSubmit: func(task interface{}) {
+			mu.Lock()
+			submitted = append(submitted, task)
+			mu.Unlock()
+		}}))
+	assert.NoErrorf(t, err, "create PoolWithFuncResult failed: %v", err)
+	defer p.Release()
+	fs := make([]*Future[int], 3)
+	for i := range fs {
+		fs[i], err = p.Invoke(i)
+		assert.NoError(t, err, "invoke shouldn't return error")
+	}
+	_, err = fs[1].Wait(ctx)
+	assert.Equal(t, ErrTaskDiscarded, err, "the future of the discarded invocation should complete")
+	mu.Lock()
+	assert.EqualValues(t, []interface{}{0, 1, 2}, submitted, "OnSubmit should receive the arguments")
+	mu.Unlock()
+
+	//交给RejectionHandler的调用，Future以ErrPoolOverload结束，RejectionHandler收到的是Invoke的参数
+	var rejected interface{}
+	p, err = NewPoolWithFuncResult(1, pf, WithNonblocking(true), WithRejectionHandler(func(task interface{}) {
+		rejected = task
+	}))
+	assert.NoErrorf(t, err, "create PoolWithFuncResult failed: %v", err)
+	defer p.Release()
+	_, err = p.Invoke(0)
+	assert.NoError(t, err, "invoke shouldn't return error")
+	f, err := p.Invoke(1)
+	assert.NoError(t, err, "rejection handler shouldn't return error")
+	_, err = f.Wait(ctx)
+	assert.Equal(t, ErrPoolOverload, err, "the future of the rejected invocation should complete")
+	assert.EqualValues(t, 1, rejected, "RejectionHandler should receive the arguments")
+}
@@ -0,0 +1,126 @@
+package ants
+
+import (
+	"context"
+	"sync"
+)
+
+//TaskGroup是提交到同一个池子中的一组任务，可以等待它们全部结束并拿到第一个错误，类似于errgroup，
+//省去了每次扇出任务都要自己配合sync.WaitGroup收集结果的麻烦。通过Pool.Group创建
+type TaskGroup struct {
+	pool          *Pool
+	ctx           context.Context //传递给组内每个任务的context，Wait返回之后(开启了WithCancelOnError时是第一个错误出现时)被取消
+	cancel        context.CancelFunc
+	cancelOnError bool
+	sem           chan struct{} //组内同时执行的任务数限制，nil表示只受池子容量的限制
+	wg            sync.WaitGroup
+	errOnce       sync.Once
+	err           error
+}
+
+//GroupOption是创建TaskGroup时的可选配置
+type GroupOption func(g *TaskGroup)
+
+//限制组内同时执行(包括在池子中排队)的任务数，它是叠加在池子容量之上的限制，n<=0表示不限制
+func WithGroupLimit(n int) GroupOption {
+	return func(g *TaskGroup) {
+		if n > 0 {
+			g.sem = make(chan struct{}, n)
+		}
+	}
+}
+
+//组内第一个任务返回错误时就取消组的context，让其他任务尽早结束，之后的Go也会直接返回context的错误
+func WithCancelOnError() GroupOption {
+	return func(g *TaskGroup) {
+		g.cancelOnError = true
+	}
+}
+
+//创建一个提交到该池子的任务组，组内的任务都会收到由ctx派生出来的context
+func (p *Pool) Group(ctx context.Context, options ...GroupOption) *TaskGroup {
+	g := &TaskGroup{pool: p}
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	for _, option := range options {
+		option(g)
+	}
+	return g
+}
+
+//Context返回组内任务收到的context
+func (g *TaskGroup) Context() context.Context {
+	return g.ctx
+}
+
+//提交一个任务到组中，达到组的并发限制时阻塞等待，直到组内有任务结束或者组的context被取消。
+//提交失败(池子关闭、过载或者context被取消)时返回对应的错误，该任务不会计入组中
+//任务发生panic时以*PanicError的形式记录为组的错误，之后依旧交由worker原有的恢复逻辑处理，
+//任务被DiscardPolicy或者DiscardOldestPolicy丢弃时记录ErrTaskDiscarded，交给了自定义的拒绝处理函数时记录ErrPoolOverload，
+//两者都同样算作结束了，之后拒绝处理函数即使执行了它也不会再计入组中
+func (g *TaskGroup) Go(task func(ctx context.Context) error) error {
+	if task == nil {
+		return ErrNilTask
+	}
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-g.ctx.Done():
+			return g.ctx.Err()
+		}
+	}
+	g.wg.Add(1)
+	if err := g.ctx.Err(); err != nil {
+		g.done()
+		return err
+	}
+	//与SubmitContext一样提交，不同的是池子不执行该任务时也要结束，否则Wait永远不会返回，
+	//finish保证拒绝处理函数事后又执行了它时不会重复结束
+	var finish sync.Once
+	err := g.pool.submit(g.ctx, poolTask{
+		fn: func() {
+			defer finish.Do(g.done)
+			defer func() {
+				if r := recover(); r != nil {
+					g.setErr(newPanicError(r))
+					panic(r)
+				}
+			}()
+			if err := task(g.ctx); err != nil {
+				g.setErr(err)
+			}
+		},
+		onDrop: func(err error) {
+			g.setErr(err)
+			finish.Do(g.done)
+		},
+	}, 0)
+	if err != nil {
+		g.done()
+	}
+	return err
+}
+
+//等待组内所有已经提交的任务结束，然后取消组的context，返回第一个出现的错误
+func (g *TaskGroup) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
+
+//组内的一个任务结束了，释放它占用的并发名额
+func (g *TaskGroup) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
+
+//只记录第一个错误
+func (g *TaskGroup) setErr(err error) {
+	g.errOnce.Do(func() {
+		g.err = err
+		if g.cancelOnError {
+			g.cancel()
+		}
+	})
+}
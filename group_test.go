@@ -0,0 +1,176 @@
+package ants
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskGroup(t *testing.T) {
+	p, err := NewPool(10)
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+
+	g := p.Group(context.Background())
+	var sum int32
+	for i := 1; i <= 100; i++ {
+		i := int32(i)
+		assert.NoError(t, g.Go(func(ctx context.Context) error {
+			atomic.AddInt32(&sum, i)
+			return nil
+		}))
+	}
+	assert.NoError(t, g.Wait())
+	assert.EqualValues(t, 5050, sum, "all tasks should be finished after Wait")
+	assert.Error(t, g.Context().Err(), "context should be canceled after Wait")
+	assert.Equal(t, ErrNilTask, g.Go(nil), "nil task should be rejected")
+
+	//没有开启WithCancelOnError时，出错不影响其他任务
+	errFirst := errors.New("first")
+	g = p.Group(context.Background())
+	var finished int32
+	for i := 0; i < 10; i++ {
+		i := i
+		assert.NoError(t, g.Go(func(ctx context.Context) error {
+			if i == 0 {
+				return errFirst
+			}
+			time.Sleep(10 * time.Millisecond)
+			if ctx.Err() == nil {
+				atomic.AddInt32(&finished, 1)
+			}
+			return nil
+		}))
+	}
+	assert.Equal(t, errFirst, g.Wait(), "Wait should return the first error")
+	assert.EqualValues(t, 9, finished, "other tasks shouldn't be canceled")
+}
+
+func TestTaskGroupCancelOnError(t *testing.T) {
+	p, err := NewPool(10)
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+
+	errFirst := errors.New("first")
+	g := p.Group(context.Background(), WithCancelOnError())
+	assert.NoError(t, g.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	assert.NoError(t, g.Go(func(ctx context.Context) error { return errFirst }))
+	assert.Equal(t, errFirst, g.Wait(), "Wait should return the first error rather than the cancellation")
+	assert.Equal(t, context.Canceled, g.Go(func(ctx context.Context) error { return nil }),
+		"Go should fail after the group is canceled")
+
+	g = p.Group(context.Background(), WithCancelOnError())
+	assert.NoError(t, g.Go(func(ctx context.Context) error { panic("oops") }))
+	var pe *PanicError
+	assert.True(t, errors.As(g.Wait(), &pe), "panic should be reported as *PanicError")
+	assert.EqualValues(t, "oops", pe.Value)
+}
+
+func TestTaskGroupLimit(t *testing.T) {
+	p, err := NewPool(10)
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+
+	g := p.Group(context.Background(), WithGroupLimit(2))
+	var running, peak int32
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, g.Go(func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		}))
+	}
+	assert.NoError(t, g.Wait())
+	assert.EqualValues(t, 2, peak, "group limit should be applied on top of the pool capacity")
+
+	//等待组的并发名额时可以被ctx取消
+	ctx, cancel := context.WithCancel(context.Background())
+	g = p.Group(ctx, WithGroupLimit(1))
+	block := make(chan struct{})
+	assert.NoError(t, g.Go(func(ctx context.Context) error {
+		<-block
+		return nil
+	}))
+	time.AfterFunc(10*time.Millisecond, cancel)
+	assert.Equal(t, context.Canceled, g.Go(func(ctx context.Context) error { return nil }))
+	close(block)
+	assert.NoError(t, g.Wait())
+}
+
+func TestTaskGroupDiscarded(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithNonblocking(true), WithRejectionPolicy(DiscardPolicy)},
+		{WithQueueSize(1), WithRejectionPolicy(DiscardOldestPolicy)},
+	} {
+		p, err := NewPool(1, opts...)
+		assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+
+		block := make(chan struct{})
+		g := p.Group(context.Background())
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, g.Go(func(context.Context) error {
+				<-block
+				return nil
+			}), "discarding policies shouldn't return error")
+		}
+		close(block)
+		//被丢弃的任务也算结束了，Wait不能一直阻塞下去
+		errCh := make(chan error, 1)
+		go func() { errCh <- g.Wait() }()
+		select {
+		case err = <-errCh:
+			assert.Equal(t, ErrTaskDiscarded, err, "the discarded task should be reported")
+		case <-time.After(time.Second):
+			t.Fatal("wait should return after the discarded task")
+		}
+		p.Release()
+	}
+}
+
+func TestTaskGroupRejectionHandler(t *testing.T) {
+	var rejected func()
+	p, err := NewPool(1, WithNonblocking(true), WithRejectionHandler(func(task interface{}) {
+		rejected = task.(func())
+	}))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+
+	block := make(chan struct{})
+	g := p.Group(context.Background())
+	assert.NoError(t, g.Go(func(context.Context) error {
+		<-block
+		return nil
+	}))
+	var ran int32
+	assert.NoError(t, g.Go(func(context.Context) error {
+		atomic.StoreInt32(&ran, 1)
+		return nil
+	}), "rejection handler shouldn't return error")
+	close(block)
+	//交给拒绝处理函数的任务也算结束了，Wait不能一直阻塞下去
+	errCh := make(chan error, 1)
+	go func() { errCh <- g.Wait() }()
+	select {
+	case err = <-errCh:
+		assert.Equal(t, ErrPoolOverload, err, "the rejected task should be reported")
+	case <-time.After(time.Second):
+		t.Fatal("wait should return after the rejected task")
+	}
+	//拒绝处理函数事后执行它也不会重复结束
+	rejected()
+	assert.EqualValues(t, 1, atomic.LoadInt32(&ran))
+}
@@ -0,0 +1,72 @@
+package ants
+
+import "time"
+
+// TaskInfo描述了一次任务执行的上下文信息，传递给OnTaskStart与OnTaskEnd
+type TaskInfo struct {
+	WorkerID uint64        //执行该任务的worker的编号，在池子内唯一
+	Wait     time.Duration //任务从提交到开始执行的等待时间
+	Run      time.Duration //任务的执行耗时，仅在OnTaskEnd中有效
+}
+
+// Hooks是池子在各个生命周期节点上的回调，未设置的回调不会被调用。
+// 回调都是在提交者或者worker的goroutine中同步执行的，所以必须足够轻量，并且不能再向同一个池子提交任务，
+// 否则会拖慢甚至卡住池子
+type Hooks struct {
+	OnSubmit       func(task interface{})               //任务被池子接受(交给了worker或者放入了任务队列)，task是func()或者PoolWithFunc的参数
+	OnReject       func(task interface{})               //池子饱和，任务即将交给拒绝策略处理
+	OnTaskStart    func(info TaskInfo)                  //worker开始执行任务
+	OnTaskEnd      func(info TaskInfo)                  //任务正常执行结束，发生了panic的任务不会触发它
+	OnPanic        func(workerID uint64, p interface{}) //任务发生了panic，在PanicHandler之前调用
+	OnWorkerSpawn  func(workerID uint64)                //启动了一个新的worker
+	OnWorkerExpire func(workerID uint64)                //一个空闲的worker因为过期而被清理
+}
+
+// 设置池子的生命周期回调
+func WithHooks(hooks Hooks) Option {
+	return func(opts *Options) {
+		opts.Hooks = hooks
+	}
+}
+
+func (h *Hooks) submit(task interface{}) {
+	if h.OnSubmit != nil {
+		h.OnSubmit(task)
+	}
+}
+
+func (h *Hooks) reject(task interface{}) {
+	if h.OnReject != nil {
+		h.OnReject(task)
+	}
+}
+
+func (h *Hooks) taskStart(workerID uint64, wait time.Duration) {
+	if h.OnTaskStart != nil {
+		h.OnTaskStart(TaskInfo{WorkerID: workerID, Wait: wait})
+	}
+}
+
+func (h *Hooks) taskEnd(workerID uint64, wait, run time.Duration) {
+	if h.OnTaskEnd != nil {
+		h.OnTaskEnd(TaskInfo{WorkerID: workerID, Wait: wait, Run: run})
+	}
+}
+
+func (h *Hooks) panic(workerID uint64, p interface{}) {
+	if h.OnPanic != nil {
+		h.OnPanic(workerID, p)
+	}
+}
+
+func (h *Hooks) workerSpawn(workerID uint64) {
+	if h.OnWorkerSpawn != nil {
+		h.OnWorkerSpawn(workerID)
+	}
+}
+
+func (h *Hooks) workerExpire(workerID uint64) {
+	if h.OnWorkerExpire != nil {
+		h.OnWorkerExpire(workerID)
+	}
+}
@@ -0,0 +1,141 @@
+package ants
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//VegasLimiter每观测到这么多个样本就重新探测一次空载耗时，避免下游变慢之后基准一直停留在过去的最小值上
+const vegasProbeInterval = 1000
+
+//ConcurrencyLimiter根据观测到的任务耗时动态计算池子允许同时执行的任务数，实现必须是并发安全的。
+//开启之后正在执行任务的worker数量达到Limit()时，新提交的任务与池子满载时一样处理，即按照Nonblocking、
+//MaxBlockingTasks、任务队列以及拒绝策略等配置阻塞、排队或者被拒绝，池子的容量依旧是worker数量的硬上限
+type ConcurrencyLimiter interface {
+	Limit() int                              //当前允许同时执行的任务数，必须大于0
+	Observe(rtt time.Duration, inflight int) //观测到一个任务执行完毕，rtt是它的执行耗时，inflight是它开始执行时正在执行的任务数(包括它自己)
+}
+
+//设置并发限制器
+func WithConcurrencyLimiter(limiter ConcurrencyLimiter) Option {
+	return func(opts *Options) {
+		opts.ConcurrencyLimiter = limiter
+	}
+}
+
+//limitRange是限制器共用的上下限以及当前的限制值
+type limitRange struct {
+	min, max int
+	limit    int32 //取整之后的限制值，Limit()无锁读取
+}
+
+func newLimitRange(initial, min, max int) limitRange {
+	if min <= 0 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	r := limitRange{min: min, max: max}
+	r.store(float64(initial))
+	return r
+}
+
+func (r *limitRange) Limit() int {
+	return int(atomic.LoadInt32(&r.limit))
+}
+
+//将限制值限制在[min, max]之间并保存，返回限制之后的值
+func (r *limitRange) store(limit float64) float64 {
+	limit = math.Max(float64(r.min), math.Min(float64(r.max), limit))
+	atomic.StoreInt32(&r.limit, int32(limit))
+	return limit
+}
+
+//VegasLimiter借鉴了TCP Vegas的拥塞控制：以观测到的最小耗时作为空载耗时，
+//根据 排队数 = 限制值 * (1 - 空载耗时/耗时) 估算下游的排队情况，排队少时加1，排队多时减1
+type VegasLimiter struct {
+	limitRange
+	mu       sync.Mutex
+	estimate float64       //未取整的限制值
+	noLoad   time.Duration //空载耗时
+	samples  int           //距离上一次探测空载耗时的样本数
+}
+
+//创建一个VegasLimiter，初始的限制值为initial，之后在[min, max]之间调整
+func NewVegasLimiter(initial, min, max int) *VegasLimiter {
+	l := &VegasLimiter{limitRange: newLimitRange(initial, min, max)}
+	l.estimate = float64(l.Limit())
+	return l
+}
+
+func (l *VegasLimiter) Observe(rtt time.Duration, inflight int) {
+	if rtt <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples++
+	if l.noLoad == 0 || rtt < l.noLoad || l.samples >= vegasProbeInterval {
+		l.noLoad, l.samples = rtt, 0
+		return
+	}
+	//并发没有用到限制值的一半，耗时反映不出限制值是否合适，不做调整
+	if inflight*2 < int(l.estimate) {
+		return
+	}
+	queue := math.Ceil(l.estimate * (1 - float64(l.noLoad)/float64(rtt)))
+	scale := math.Log10(l.estimate)
+	switch {
+	case queue <= math.Max(1, 3*scale):
+		l.estimate = l.store(l.estimate + math.Max(1, scale))
+	case queue >= math.Max(2, 6*scale):
+		l.estimate = l.store(l.estimate - math.Max(1, scale))
+	}
+}
+
+//GradientLimiter以耗时的长期均值作为基准，按照 梯度 = 基准/耗时 (限制在[0.5, 1]之间) 调整限制值：
+//新限制值 = 限制值 * 梯度 + sqrt(限制值)，其中sqrt(限制值)是允许的排队数，再与旧的限制值做指数平滑
+type GradientLimiter struct {
+	limitRange
+	mu       sync.Mutex
+	estimate float64       //未取整的限制值
+	longRTT  time.Duration //耗时的长期均值
+}
+
+//创建一个GradientLimiter，初始的限制值为initial，之后在[min, max]之间调整
+func NewGradientLimiter(initial, min, max int) *GradientLimiter {
+	l := &GradientLimiter{limitRange: newLimitRange(initial, min, max)}
+	l.estimate = float64(l.Limit())
+	return l
+}
+
+const (
+	gradientLongWindow = 600 //计算长期均值的窗口大小
+	gradientSmoothing  = 0.2 //新旧限制值的平滑系数
+)
+
+func (l *GradientLimiter) Observe(rtt time.Duration, inflight int) {
+	if rtt <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.longRTT == 0 {
+		l.longRTT = rtt
+	} else {
+		l.longRTT += (rtt - l.longRTT) / gradientLongWindow
+	}
+	//耗时已经恢复，长期均值却还停留在过去的高位时，让它更快地降下来
+	if l.longRTT > 2*rtt {
+		l.longRTT = l.longRTT * 95 / 100
+	}
+	if inflight*2 < int(l.estimate) {
+		return
+	}
+	gradient := math.Max(0.5, math.Min(1, float64(l.longRTT)/float64(rtt)))
+	next := l.estimate*gradient + math.Sqrt(l.estimate)
+	l.estimate = l.store(l.estimate*(1-gradientSmoothing) + next*gradientSmoothing)
+}
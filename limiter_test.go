@@ -0,0 +1,157 @@
+package ants
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVegasLimiter(t *testing.T) {
+	l := NewVegasLimiter(10, 2, 20)
+	assert.EqualValues(t, 10, l.Limit())
+	l.Observe(10*time.Millisecond, 10)
+	for i := 0; i < 10; i++ {
+		l.Observe(10*time.Millisecond, l.Limit())
+	}
+	assert.EqualValues(t, 20, l.Limit(), "limit should grow while the rtt stays at no-load")
+	l.Observe(10*time.Millisecond, 1)
+	assert.EqualValues(t, 20, l.Limit(), "limit shouldn't change when it isn't used up")
+	for i := 0; i < 100; i++ {
+		l.Observe(50*time.Millisecond, l.Limit())
+	}
+	assert.True(t, l.Limit() <= 3, "limit should shrink when the rtt goes up")
+	assert.EqualValues(t, 1, NewVegasLimiter(0, 0, 0).Limit(), "limit should be at least 1")
+}
+
+func TestGradientLimiter(t *testing.T) {
+	l := NewGradientLimiter(10, 2, 50)
+	for i := 0; i < 100; i++ {
+		l.Observe(10*time.Millisecond, l.Limit())
+	}
+	assert.EqualValues(t, 50, l.Limit(), "limit should grow while the rtt is stable")
+	for i := 0; i < 100; i++ {
+		l.Observe(100*time.Millisecond, l.Limit())
+	}
+	assert.True(t, l.Limit() < 50, "limit should shrink when the rtt goes up")
+}
+
+type fixedLimiter int
+
+func (l fixedLimiter) Limit() int               { return int(l) }
+func (fixedLimiter) Observe(time.Duration, int) {}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	p, err := NewPool(10, WithConcurrencyLimiter(fixedLimiter(2)), WithNonblocking(true))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	ch := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, p.Submit(func() { <-ch }))
+	}
+	assert.Equal(t, ErrPoolOverload, p.Submit(demoFunc), "submission beyond the limit should be rejected")
+	assert.EqualValues(t, 2, p.Stats().Limit)
+	close(ch)
+	time.Sleep(10 * time.Millisecond)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	assert.NoError(t, p.Submit(wg.Done), "submission under the limit should be accepted")
+	wg.Wait()
+
+	var running, maxRunning int32
+	var mu sync.Mutex
+	p1, err := NewPoolWithFunc(10, func(interface{}) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		wg.Done()
+	}, WithConcurrencyLimiter(fixedLimiter(3)), WithQueueSize(20))
+	assert.NoErrorf(t, err, "create TimingPoolWithFunc failed: %v", err)
+	defer p1.Release()
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		assert.NoError(t, p1.Invoke(i), "submission beyond the limit should be queued")
+	}
+	assert.True(t, p1.QueueLen() > 0, "submission beyond the limit should be queued")
+	wg.Wait()
+	assert.EqualValues(t, 3, maxRunning, "concurrency should be limited")
+	assert.EqualValues(t, 3, p1.Stats().WorkersSpawned, "no more workers than the limit should be spawned")
+
+	p2, err := NewPool(1, WithConcurrencyLimiter(NewVegasLimiter(1, 1, 4)))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p2.Release()
+	assert.EqualValues(t, 1, p2.Stats().Limit, "limit shouldn't exceed the capacity")
+
+	//扩容时为积压的任务开启新的worker也要受并发限制
+	p3, err := NewPool(4, WithConcurrencyLimiter(fixedLimiter(1)), WithQueueSize(4))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p3.Release()
+	block := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		assert.NoError(t, p3.Submit(func() {
+			<-block
+			wg.Done()
+		}))
+	}
+	p3.Tune(8)
+	time.Sleep(10 * time.Millisecond)
+	assert.EqualValues(t, 1, p3.Busy(), "draining the queue shouldn't exceed the limit")
+	assert.EqualValues(t, 3, p3.QueueLen(), "tasks beyond the limit should stay in the queue")
+	close(block)
+	wg.Wait()
+}
+
+// limitVar是可以随时调整限制的ConcurrencyLimiter
+type limitVar int32
+
+func (l *limitVar) Limit() int               { return int(atomic.LoadInt32((*int32)(l))) }
+func (*limitVar) Observe(time.Duration, int) {}
+func (l *limitVar) set(n int)                { atomic.StoreInt32((*int32)(l), int32(n)) }
+
+func TestConcurrencyLimitGrows(t *testing.T) {
+	l := new(limitVar)
+	l.set(4)
+	p, err := NewPool(4, WithConcurrencyLimiter(l), WithExpiryDuration(time.Minute))
+	assert.NoErrorf(t, err, "create TimingPool failed: %v", err)
+	defer p.Release()
+	assert.EqualValues(t, 4, p.Warmup(4))
+
+	//限制降为1之后，其余的提交者都阻塞等待
+	l.set(1)
+	block := make(chan struct{})
+	assert.NoError(t, p.Submit(func() { <-block }))
+	var started sync.WaitGroup
+	started.Add(3)
+	for i := 0; i < 3; i++ {
+		go func() { _ = p.Submit(func() { started.Done(); <-block }) }()
+	}
+	for p.Stats().Waiting != 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	//限制放宽之后，排队的提交者不用等到正在执行的任务结束，就能用上空闲的worker
+	l.set(4)
+	go func() { _ = p.Submit(func() {}) }()
+	done := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the blocked submitters should get the idle workers once the limit grows")
+	}
+	assert.EqualValues(t, 4, p.Busy(), "the pool should run up to the grown limit")
+	close(block)
+}
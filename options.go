@@ -1,6 +1,9 @@
 package ants
 
-import "time"
+import (
+	"runtime"
+	"time"
+)
 
 //通过函数类型来表示选项配置更具灵活性
 //一个Option类型表示一个选项配置
@@ -21,11 +24,20 @@ func loadOptions(options ...Option) *Options {
 //----------------------Options结构体包含了初始化一个ants池时需要的所有参数选项-----------------------------------------
 type Options struct {
 	ExpiryDuration time.Duration //是worker的过期时长，在空闲队列中的worker的最新一次运行时间与当前时间之差如果大于这个值则表示已过期，定时清理任务会清理掉这个worker 单位秒
-	PreAlloc bool 	//在初始化Pool时是否对内存进行预分配。
+	PreAlloc bool 	//在初始化Pool时是否对内存进行预分配，同时预先开启一批worker，见WithPreAlloc
 	MaxBlockingTasks int //pool.Submit上的goroutine阻止的最大任务数量，0表示没有限制
 	Nonblocking bool //任务提交是否是不闭塞的
 	PanicHandler func(interface{}) //自定义的处理每个worker中发生的panic函数
 	Logger Logger //自定义日志驱动
+	QueueSize int //池子满载时缓存任务的队列长度，开启之后提交任务不再阻塞，只有队列满了才会返回ErrPoolOverload，0表示不开启
+	RejectionPolicy RejectionPolicy //池子饱和时对新任务的处理策略，默认是AbortPolicy
+	RejectionHandler RejectionHandler //自定义的拒绝处理函数，优先于RejectionPolicy
+	Hooks Hooks //池子在各个生命周期节点上的回调
+	Autoscaler *AutoscalerConfig //自动扩缩容的配置，nil表示不开启
+	ConcurrencyLimiter ConcurrencyLimiter //根据任务耗时动态限制同时执行的任务数，nil表示只受容量的限制
+	MinIdleWorkers int //定期清理过期worker时至少保留的空闲worker数量，开启了PreAlloc时创建池子就会预先开启这么多worker，0表示不保留(此时PreAlloc预先开启GOMAXPROCS个)
+	MaxWaitDuration time.Duration //阻塞模式下提交者等待空闲worker的最长时间，超过之后返回ErrSubmitTimeout，0表示不限制
+	PriorityAging time.Duration //优先级的老化间隔，等待者与排队的任务每等待这么久优先级就相当于提高1，0表示不老化
 }
 
 //创建goroutine池的时候指明所有的参数配置
@@ -47,12 +59,22 @@ func WithExpiryDuration(expiryDuration time.Duration) Option {
 	}
 }
 //在初始化Pool时是否对内存进行预分配。
+//创建池子(以及Reboot)时还会通过Warmup预先开启worker：设置了WithMinIdleWorkers的话开启MinIdleWorkers个，
+//否则开启GOMAXPROCS个，都不超过池子的容量。没有设置WithMinIdleWorkers时，它们空闲过期之后依旧会被清理掉
 //@reviser sam@2020-04-17 10:41:54
 func WithPreAlloc(preAlloc bool) Option {
 	return func(opts *Options) {
 		opts.PreAlloc = preAlloc
 	}
 }
+
+//开启了PreAlloc时预先开启的worker个数，Warmup会再按照池子的容量截断
+func (opts *Options) preAllocWorkers() int {
+	if opts.MinIdleWorkers > 0 {
+		return opts.MinIdleWorkers
+	}
+	return runtime.GOMAXPROCS(0)
+}
 //pool.Submit上的goroutine阻止的最大任务数量，0表示没有限制
 //@reviser sam@2020-04-17 10:41:40
 func WithMaxBlockingTasks(maxBlockingTasks int) Option {
@@ -84,3 +106,52 @@ func WithLogger(logger Logger) Option {
 		opts.Logger = logger
 	}
 }
+
+//池子满载时缓存任务的队列长度，开启之后Submit将任务放入队列即返回，由worker在执行完手头的任务后消费，
+//队列满了才会返回ErrPoolOverload，此时Nonblocking与MaxBlockingTasks都不起作用。
+func WithQueueSize(queueSize int) Option {
+	return func(opts *Options) {
+		opts.QueueSize = queueSize
+	}
+}
+
+//定期清理过期worker时至少保留n个空闲的worker，避免池子在一段空闲之后缩到0，下一波任务又要承担开启goroutine以及栈增长的开销，
+//同时开启了PreAlloc的话，创建池子(以及Reboot)时就会通过Warmup预先开启n个worker
+func WithMinIdleWorkers(n int) Option {
+	return func(opts *Options) {
+		opts.MinIdleWorkers = n
+	}
+}
+
+//阻塞模式下提交者等待空闲worker的最长时间，超过之后放弃等待并返回ErrSubmitTimeout，0表示不限制。
+//与SubmitTimeout/InvokeTimeout同时指定时，以较短的那个为准
+func WithMaxWaitDuration(d time.Duration) Option {
+	return func(opts *Options) {
+		opts.MaxWaitDuration = d
+	}
+}
+
+//计算提交者等待空闲worker的截止时间，timeout是单次提交指定的超时时间，返回零值表示不限制
+func (opts *Options) waitDeadline(timeout time.Duration) time.Time {
+	if max := opts.MaxWaitDuration; max > 0 && (timeout <= 0 || max < timeout) {
+		timeout = max
+	}
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+//池子饱和时对新任务的处理策略
+func WithRejectionPolicy(policy RejectionPolicy) Option {
+	return func(opts *Options) {
+		opts.RejectionPolicy = policy
+	}
+}
+
+//自定义池子饱和时对新任务的处理函数
+func WithRejectionHandler(handler RejectionHandler) Option {
+	return func(opts *Options) {
+		opts.RejectionHandler = handler
+	}
+}
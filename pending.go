@@ -0,0 +1,58 @@
+package ants
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+//pendingTasks统计已经被池子接受但还没有执行结束的任务数(包括交给worker途中的、在任务队列中排队的以及正在执行的)，
+//与running不同，它不包括空闲的worker，Wait据此判断池子是否已经把提交的任务都执行完了
+type pendingTasks struct {
+	n       int32
+	mu      sync.Mutex
+	drained chan struct{} //有调用者在Wait时创建，任务数降为0时关闭
+}
+
+//增加delta个待完成的任务，delta为负数表示有任务结束了(或者最终没有被接受)
+func (t *pendingTasks) add(delta int) {
+	if atomic.AddInt32(&t.n, int32(delta)) != 0 {
+		return
+	}
+	t.mu.Lock()
+	//加锁之前可能又有新的任务被接受了，此时留给那个任务结束时再通知
+	if t.drained != nil && atomic.LoadInt32(&t.n) == 0 {
+		close(t.drained)
+		t.drained = nil
+	}
+	t.mu.Unlock()
+}
+
+//一个任务结束了
+func (t *pendingTasks) done() {
+	t.add(-1)
+}
+
+func (t *pendingTasks) len() int {
+	return int(atomic.LoadInt32(&t.n))
+}
+
+//阻塞直到待完成的任务数降为0，ctx结束时返回ctx.Err()
+func (t *pendingTasks) wait(ctx context.Context) error {
+	t.mu.Lock()
+	if atomic.LoadInt32(&t.n) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.drained == nil {
+		t.drained = make(chan struct{})
+	}
+	drained := t.drained
+	t.mu.Unlock()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
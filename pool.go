@@ -1,6 +1,7 @@
 package ants
 
 import (
+	"context"
 	"github.com/panjf2000/ants/v2/internal"
 	"sync"
 	"sync/atomic"
@@ -15,41 +16,70 @@ import (
 //@todo 一个Pool结构体吧了
 type Pool struct {
 	capacity int32 //是该Pool的容量，也就是开启worker数量的上限，每一个worker绑定一个goroutine
-	running int32  //是当前正在执行任务的worker(goroutines)数量
+	running int32  //是当前存活的worker(goroutines)数量，包括空闲的worker，正在执行任务的数量见inflight
+	inflight int32 //正在执行中的任务数量，running还包括了空闲的worker
 	workers workerArray 	// workers is a slice that store the available workers.
 	state int32 //该池子是否已经关闭了,1表示关闭了,todo v1版本是用字段release表示的额
 	lock sync.Locker //lock是一个互斥锁/读写锁的接口类型，用以支持Pool的同步操作,v1版本这里是 sync.Mutex
-	cond *sync.Cond //该条件变量是为了等待获取一个空闲的worker
+	waiters waitQueue //等待获取一个空闲worker的提交者队列,v1版本以及之前是用条件变量sync.Cond实现的,无法中途放弃等待
 	workerCache sync.Pool 	//原子操作之临时对象池workerCache加速了函数retrieveWorker中可用worker的获取。
 	blockingNum int 	//当前已经处于阻塞中的任务个数(即都在等待空闲worker的到来)
+	tasks taskQueue[poolTask] //池子满载时用来缓存任务的队列，长度由Options.QueueSize决定，0表示不开启
+	stats *poolStats //各项累计的统计数据
+	pending pendingTasks //已经接受但还没有执行结束的任务数，Wait用
+	stopBackground context.CancelFunc //停止后台的goroutine(定期清理过期worker以及自动扩缩容)，Release时调用，Reboot时会重新开启
 	options *Options
 }
 
+//poolTask是放在任务队列中的任务，since是它的提交时间，用来统计等待时长，priority是它的优先级，
+//onDrop在提交返回了nil、池子却不会执行的任务上调用(被拒绝策略丢弃，或者交给了自定义的拒绝处理函数)，
+//让等待它结果的Future或者TaskGroup以err结束
+type poolTask struct {
+	fn       func()
+	since    time.Time
+	priority int
+	onDrop   func(err error)
+}
+
+//通知任务的提交者池子不会执行该任务了，必须在锁之外调用
+func (t poolTask) dropped(err error) {
+	if t.onDrop != nil {
+		t.onDrop(err)
+	}
+}
+
 //定期清理池子中过期的worker
 //@reviser sam@2020-04-17 14:45:25
-func (p *Pool) periodicallyPurge() {
+//ctx结束即池子被关闭了，此时退出，不能依赖定时器到点之后再检查池子的状态，否则在此之前Reboot的话就会同时存在两个清理goroutine
+func (p *Pool) periodicallyPurge(ctx context.Context) {
 	//开启一个连续定时器,既然worker的过期时间是expiryDuration,那定时器就每隔expiryDuration进行清理是再好不过了
 	heartbeat := time.NewTicker(p.options.ExpiryDuration)
 	defer heartbeat.Stop()
 	//定期循环
-	for range heartbeat.C {
-		//Load 只保证读取的不是正在写入的值
-		if atomic.LoadInt32(&p.state) == CLOSED {
-			break
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
 		}
         //(1)清理过期workers,以前是未封装成方法的，赤裸裸的遍历所有的workers，然后比对过期时间进行删除的,现在不光封装成方法了，而且采用了二分查找的方式
 		p.lock.Lock()
-		expiredWorkers := p.workers.retrieveExpiry(p.options.ExpiryDuration)
+		expiredWorkers := p.keepMinIdle(p.workers.retrieveExpiry(p.options.ExpiryDuration))
+		p.admitWaiters() //并发限制放宽了的话，最迟在这里让排队的提交者用上空闲的worker
 		p.lock.Unlock()
 		//(2)通知过时的worker停止。
 		//该通知必须在p.lock之外，因为w.task可能会阻塞并且可能会花费大量时间,如果许多workers位于非本地CPU上.
 		//@todo 所以v1版本在这里的处理也是放到lock中的，所以是非常不理智的
 		for i := range expiredWorkers {
-			expiredWorkers[i].task <- nil
+			p.options.Hooks.workerExpire(expiredWorkers[i].workerID())
+			expiredWorkers[i].stop()
 		}
-		//(3)当该池子中没有正在执行任务的worker了，则可以尝试唤醒那些还卡在p.cond.Wait()的程序了
+		atomic.AddUint64(&p.stats.workersPurged, uint64(len(expiredWorkers)))
+		//(3)当该池子中没有正在执行任务的worker了，则可以尝试唤醒那些还卡在p.waiters.wait()的程序了
 		if p.Running() == 0 {
-			p.cond.Broadcast()
+			p.lock.Lock()
+			p.waiters.broadcast()
+			p.lock.Unlock()
 		}
 	}
 }
@@ -59,30 +89,269 @@ func (p *Pool) periodicallyPurge() {
 
 //提交任务到池子中，真牛逼，任务是提交给该池子中的某个worker的task通道上的，每个worker都有自己的专属通道
 //@todo v1版是无脑式的提交，v2版更加灵活一下，可以设置提交的阻塞数量的
+//task不能为nil，否则返回ErrNilTask
 //@reviser sam@2020-04-17 16:29:20
 func (p *Pool) Submit(task func()) error {
 	//判断pool是否关闭了  当p.state被设置为1即表示释放了，即已经关闭了
 	if atomic.LoadInt32(&p.state) == CLOSED {
 		return ErrPoolClosed
 	}
-	//获取一个可用worker之后，将task添加到worker的task字段中
-	//这里可以看成开辟了一个任务通道，且是该任务通道的生产端
-	var w *goWorker
-	if w = p.retrieveWorker(); w == nil {
+	if task == nil {
+		return ErrNilTask
+	}
+	return p.submit(context.Background(), poolTask{fn: task}, 0)
+}
+
+//与Submit一样提交任务，不同的是阻塞等待空闲worker超过timeout之后就会放弃并返回ErrSubmitTimeout，
+//timeout<=0时只受WithMaxWaitDuration的限制
+func (p *Pool) SubmitTimeout(task func(), timeout time.Duration) error {
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		return ErrPoolClosed
+	}
+	if task == nil {
+		return ErrNilTask
+	}
+	return p.submit(context.Background(), poolTask{fn: task}, timeout)
+}
+
+//以priority的优先级提交任务，池子满载时，阻塞等待的提交者以及任务队列中的任务都按照优先级从高到低被服务，
+//优先级相同时先来先服务，Submit的优先级为0。开启了WithPriorityAging时，等待越久优先级越高
+func (p *Pool) SubmitWithPriority(task func(), priority int) error {
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		return ErrPoolClosed
+	}
+	if task == nil {
+		return ErrNilTask
+	}
+	return p.submit(context.Background(), poolTask{fn: task, priority: priority}, 0)
+}
+
+//批量提交任务，只加一次锁就尽可能多地取出空闲worker，不够的再按照池子的余量一次性开启新的worker，
+//开启了任务队列时再接着放入队列，剩下的任务最后逐个按照阻塞/非阻塞等配置提交。
+//返回成功提交的任务个数(即Submit会返回nil的任务个数)，遇到第一个提交失败的任务就停止并返回它的错误，
+//tasks中有nil时不提交任何任务并返回ErrNilTask
+func (p *Pool) SubmitBatch(tasks []func()) (int, error) {
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		return 0, ErrPoolClosed
+	}
+	for _, task := range tasks {
+		if task == nil {
+			return 0, ErrNilTask
+		}
+	}
+	now := time.Now()
+	p.pending.add(len(tasks)) //与submit一样先计入，剩下逐个提交的任务再减掉
+	p.lock.Lock()
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		p.lock.Unlock()
+		p.pending.add(-len(tasks))
+		return 0, ErrPoolClosed
+	}
+	var (
+		workers []*goWorker
+		spawn   int
+		queued  int
+	)
+	//已经有提交者在排队时，整批任务都不能插队，只能逐个提交
+	if p.waiters.len() == 0 {
+		quota := p.batchQuota(len(tasks))
+		for len(workers) < quota {
+			w, _ := p.workers.detach().(*goWorker)
+			if w == nil {
+				break
+			}
+			workers = append(workers, w)
+		}
+		if spawn = quota - len(workers); spawn > p.Cap()-p.Running() {
+			spawn = p.Cap() - p.Running()
+		}
+		if spawn > 0 {
+			atomic.AddInt32(&p.running, int32(spawn)) //先预留容量，解锁之后再开启
+		} else {
+			spawn = 0
+		}
+		//剩下的任务先在任务队列中预留位置，放不下的再交给下面逐个提交
+		for range tasks[len(workers)+spawn:] {
+			if !p.tasks.reserve() {
+				break
+			}
+			queued++
+		}
+	}
+	p.lock.Unlock()
+	for i := 0; i < spawn; i++ {
+		w := p.workerCache.Get().(*goWorker)
+		w.start()
+		workers = append(workers, w)
+	}
+	for i, w := range workers {
+		p.stats.taskSubmitted(0)
+		p.options.Hooks.submit(tasks[i])
+		w.task <- poolTask{fn: tasks[i], since: now}
+	}
+	n := len(workers)
+	if queued > 0 {
+		//先触发提交的回调以及统计，再放入队列，否则worker有可能在此之前就执行完了它们
+		pts := make([]poolTask, queued)
+		for i, task := range tasks[n : n+queued] {
+			p.stats.taskSubmitted(0)
+			p.options.Hooks.submit(task)
+			pts[i] = poolTask{fn: task, since: now}
+		}
+		p.enqueue(pts...)
+	}
+	n += queued
+	p.pending.add(n - len(tasks))
+	for _, task := range tasks[n:] {
+		if err := p.submit(context.Background(), poolTask{fn: task}, 0); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+//与Submit一样提交任务，不同的是等待空闲worker的过程中一旦ctx被取消或者超时，就会放弃等待并返回ctx.Err()，
+//同时ctx也会传递给task，便于任务在执行过程中感知到取消
+func (p *Pool) SubmitContext(ctx context.Context, task func(context.Context)) error {
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		return ErrPoolClosed
+	}
+	if task == nil {
+		return ErrNilTask
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.submit(ctx, poolTask{fn: func() { task(ctx) }}, 0)
+}
+
+//获取一个可用worker之后，将task添加到worker的task字段中
+//这里可以看成开辟了一个任务通道，且是该任务通道的生产端
+//timeout是阻塞等待空闲worker的超时时间，0表示只受WithMaxWaitDuration的限制
+func (p *Pool) submit(ctx context.Context, pt poolTask, timeout time.Duration) error {
+	//先计入待完成的任务，否则放入任务队列的任务有可能在计入之前就被执行完了
+	p.pending.add(1)
+	//开启了任务队列时，返回的w有可能为nil，即已经在队列中为任务预留了位置
+	pt.since = time.Now()
+	w, err := p.retrieveWorker(ctx, pt, timeout)
+	if err != nil {
+		p.pending.done()
+	}
+	switch err {
+	case nil:
+	case ErrPoolOverload: //池子饱和了，交给拒绝策略处理
+		return p.reject(pt)
+	case ErrSubmitTimeout: //等待超时不算池子过载，单独统计，也不交给拒绝策略
+		atomic.AddUint64(&p.stats.timedOut, 1)
+		return err
+	case ErrTaskDiscarded: //阻塞等待时被更新的任务挤掉了，已经算在了丢弃的任务中，任务没有被接受，所以提交者拿到的依旧是ErrPoolOverload
 		return ErrPoolOverload
+	default:
+		return err
+	}
+	//回调以及统计必须在任务对worker可见之前完成，否则有可能先于它的OnTaskStart/OnTaskEnd
+	p.stats.taskSubmitted(pt.priority)
+	p.options.Hooks.submit(pt.fn)
+	if w != nil {
+		w.task <- pt
+	} else {
+		p.enqueue(pt)
 	}
-	w.task <- task
 	return nil
 }
 
-// Running returns the number of the currently running goroutines.
+//按照拒绝策略处理池子饱和时提交的任务
+func (p *Pool) reject(task poolTask) error {
+	atomic.AddUint64(&p.stats.rejected, 1)
+	p.options.Hooks.reject(task.fn)
+	if h := p.options.RejectionHandler; h != nil {
+		h(task.fn)
+		//对提交者来说任务被接受了，但是池子不会执行它，怎么处理由拒绝处理函数决定
+		task.dropped(ErrPoolOverload)
+		return nil
+	}
+	switch p.options.RejectionPolicy {
+	case CallerRunsPolicy:
+		task.fn()
+		return nil
+	case DiscardPolicy, DiscardOldestPolicy: //走到这里说明没有更老的任务可以丢弃了，只能丢弃新任务本身
+		atomic.AddUint64(&p.stats.discarded, 1)
+		task.dropped(ErrTaskDiscarded)
+		return nil
+	default:
+		return ErrPoolOverload
+	}
+}
+
+//返回当前运行的worker(goroutine)数量，与Alive一样包括了空闲的worker，正在执行任务的数量见Busy
 func (p *Pool) Running() int {
 	return int(atomic.LoadInt32(&p.running))
 }
 
-// Free returns the available goroutines to work.
+//返回当前存活的worker数量，包括正在执行任务的以及空闲的
+func (p *Pool) Alive() int {
+	return p.Running()
+}
+
+//返回正在执行任务的worker数量
+func (p *Pool) Busy() int {
+	return int(atomic.LoadInt32(&p.inflight))
+}
+
+//返回空闲的worker数量，即存活着但是在空闲队列中等待任务的worker
+func (p *Pool) Idle() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.workers.len()
+}
+
+//返回任务队列中等待执行的任务个数
+func (p *Pool) QueueLen() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.tasks.len()
+}
+
+//返回被拒绝策略丢弃的任务总数
+func (p *Pool) Discarded() int {
+	return int(atomic.LoadUint64(&p.stats.discarded))
+}
+
+//阻塞直到目前为止提交的任务都执行结束(包括在任务队列中排队的)，
+//等待期间新提交的任务也要等它们结束，所以池子一直繁忙的话可能会一直等下去
+func (p *Pool) Wait() {
+	_ = p.pending.wait(context.Background())
+}
+
+//与Wait一样等待提交的任务都执行结束，ctx结束时放弃等待并返回ctx.Err()
+func (p *Pool) WaitContext(ctx context.Context) error {
+	return p.pending.wait(ctx)
+}
+
+//返回池子当前的统计快照
+func (p *Pool) Stats() PoolStats {
+	ps := PoolStats{
+		Capacity: p.Cap(),
+		Limit:    p.limit(),
+		Running:  p.Running(),
+		Busy:     p.Busy(),
+	}
+	p.lock.Lock()
+	ps.Idle = p.workers.len()
+	ps.Waiting = p.blockingNum
+	ps.Queued = p.tasks.len()
+	waiting := make(map[int]int)
+	p.waiters.countPriorities(waiting)
+	p.tasks.countPriorities(waiting)
+	p.lock.Unlock()
+	p.stats.load(&ps, waiting)
+	return ps
+}
+
+//返回还可以同时执行的任务数，即容量减去正在执行任务的worker数量，空闲的worker不占用名额
 func (p *Pool) Free() int {
-	return p.Cap() - p.Running()
+	return p.Cap() - p.Busy()
 }
 
 // Cap returns the capacity of this pool.
@@ -90,29 +359,171 @@ func (p *Pool) Cap() int {
 	return int(atomic.LoadInt32(&p.capacity))
 }
 
-// Tune changes the capacity of this pool.
-func (p *Pool) Tune(size int) {
-	if size < 0 || p.Cap() == size || p.options.PreAlloc {
-		return
+//调整池子的容量并立即生效，返回调整之前的容量
+//1.预分配的池子需要在持有锁的情况下重新分配loopQueue
+//2.缩容时立即淘汰多余的空闲worker(最早放回来的那些)，正在执行任务的worker则会在执行完之后退出
+//3.扩容时按照多出来的容量唤醒等待中的提交者，并为任务队列中积压的任务开启新的worker
+func (p *Pool) Tune(size int) int {
+	if size < 0 {
+		return p.Cap()
+	}
+	p.lock.Lock()
+	oldCap := p.Cap()
+	if oldCap == size {
+		p.lock.Unlock()
+		return oldCap
 	}
 	atomic.StoreInt32(&p.capacity, int32(size))
+	var surplus []worker
+	if q, ok := p.workers.(*loopQueue); ok {
+		surplus = q.resize(size)
+	}
+	//被淘汰的worker还没有退出，所以要从Running()中减掉
+	if n := p.Running() - len(surplus) - size; n > 0 {
+		surplus = append(surplus, p.workers.trim(n)...)
+	}
+	for n := size - oldCap; n > 0 && p.waiters.len() > 0; n-- {
+		p.waiters.signal()
+	}
+	p.lock.Unlock()
+	//与清理过期worker一样，通知worker退出必须在锁之外进行
+	for _, w := range surplus {
+		w.stop()
+	}
+	for n := size - oldCap; n > 0 && p.drainTasks(); n-- {
+	}
+	return oldCap
 }
 
 
 //关闭池子
 //1.将state置为1
 //2.将workers归零，则对应的g自然会被gc回收掉
+//3.唤醒所有阻塞等待worker的提交者
 func (p *Pool) Release() {
 	atomic.StoreInt32(&p.state, CLOSED)
 	p.lock.Lock()
+	p.stopBackground()
 	p.workers.reset() //恢复出厂设置
+	p.waiters.broadcast() //唤醒所有还卡在retrieveWorker中的提交者，让它们返回ErrPoolClosed
 	p.lock.Unlock()
 }
 
-// Reboot reboots a released pool.
+//优雅关闭池子
+//1.与Release一样不再接受新的任务提交，并让空闲的worker退出
+//2.等待正在执行任务的worker全部退出，即Running()降为0，任务队列中已经接受的任务也会在此之前执行完
+//3.如果ctx先结束了，则返回此时还没有执行结束的任务数(正在执行的加上在任务队列中排队的)以及ctx.Err()，
+//这些任务之后依旧会被执行完
+func (p *Pool) Shutdown(ctx context.Context) (int, error) {
+	p.Release()
+	heartbeat := time.NewTicker(shutdownPollInterval)
+	defer heartbeat.Stop()
+	for p.Running() > 0 {
+		select {
+		case <-ctx.Done():
+			return p.pending.len(), ctx.Err()
+		case <-heartbeat.C:
+		}
+	}
+	return 0, nil
+}
+
+//优雅关闭池子，最多等待timeout，参考Shutdown
+func (p *Pool) ReleaseTimeout(timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return p.Shutdown(ctx)
+}
+
+//重启一个已经关闭的池子，让它与新创建的池子一样：
+//1.按照当前容量重新创建workers容器(Release之后loopQueue的长度已经被置为0了，无法再放回worker)
+//2.清零统计数据，并重新开启定期清理的goroutine
+//3.开启了PreAlloc时重新预热worker
+//blockingNum不需要重置，被Release唤醒的提交者会自己减掉它，此时强行归零反而会变成负数
 func (p *Pool) Reboot() {
 	if atomic.CompareAndSwapInt32(&p.state, CLOSED, OPENED) {
-		go p.periodicallyPurge()
+		p.lock.Lock()
+		p.workers = p.newWorkerArray()
+		p.stats.reset()
+		p.startBackground()
+		p.lock.Unlock()
+		p.warmupPreAlloc()
+	}
+}
+
+//预先开启n个worker放入空闲队列，省去之后提交任务时开启goroutine以及栈增长的开销，
+//受池子容量的限制，返回实际开启的worker数量
+func (p *Pool) Warmup(n int) int {
+	if n <= 0 || atomic.LoadInt32(&p.state) == CLOSED {
+		return 0
+	}
+	p.lock.Lock()
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		p.lock.Unlock()
+		return 0
+	}
+	if free := p.Cap() - p.Running(); n > free {
+		n = free
+	}
+	if n <= 0 {
+		p.lock.Unlock()
+		return 0
+	}
+	atomic.AddInt32(&p.running, int32(n)) //先预留容量，解锁之后再开启
+	p.lock.Unlock()
+	for i := 0; i < n; i++ {
+		w := p.workerCache.Get().(*goWorker)
+		w.start()
+		//与执行完任务一样放回池子，任务队列中有积压的任务或者有提交者在等待时会直接用上它
+		if task, ok := p.revertWorker(w); !ok {
+			w.stop()
+		} else if task.fn != nil {
+			w.task <- task
+		}
+	}
+	return n
+}
+
+//开启了PreAlloc时预热worker，个数见Options.preAllocWorkers
+func (p *Pool) warmupPreAlloc() {
+	if p.options.PreAlloc {
+		p.Warmup(p.options.preAllocWorkers())
+	}
+}
+
+//开启了WithMinIdleWorkers时，从过期的worker中留下最近放回的那些，使空闲的worker不少于MinIdleWorkers，
+//留下的worker相当于刚刚被放回，返回剩下的真正要清理的worker，必须在持有锁的情况下调用
+func (p *Pool) keepMinIdle(expired []worker) []worker {
+	keep := p.options.MinIdleWorkers - p.workers.len()
+	if keep <= 0 {
+		return expired
+	}
+	if keep > len(expired) {
+		keep = len(expired)
+	}
+	now := time.Now()
+	for _, w := range expired[len(expired)-keep:] {
+		w.(*goWorker).recycleTime = now
+		_ = p.workers.insert(w)
+	}
+	return expired[:len(expired)-keep]
+}
+
+//根据是否预分配创建存放空闲worker的容器
+func (p *Pool) newWorkerArray() workerArray {
+	if p.options.PreAlloc {
+		return newWorkerArray(loopQueueType, p.Cap())
+	}
+	return newWorkerArray(stackType, 0)
+}
+
+//开启后台的goroutine：定期清理过期worker，以及配置了的话自动扩缩容
+func (p *Pool) startBackground() {
+	var ctx context.Context
+	ctx, p.stopBackground = context.WithCancel(context.Background())
+	go p.periodicallyPurge(ctx)
+	if p.options.Autoscaler != nil {
+		go autoscale(ctx, p, p.options.Autoscaler, p.stats, p.options.Logger)
 	}
 }
 
@@ -130,10 +541,11 @@ func (p *Pool) decRunning() {
 //从池子中返回一个可用的worker用来执行任务
 //1.优先先从worker.items中获取空闲的worker
 //2.如果未超过池子限制，则从临时对象池中获取即可(没有会按照New字段创建新的worker),总之从临时对象池中获取的worker都是需要重新run的
-//@return 返回w证明是成功的，返回nil，则证明是too many goroutines blocked on submit or Nonblocking is set true
+//3.池子满载时如果开启了任务队列，则在队列中为task预留一个位置，此时返回的w和error都是nil，调用者随后通过enqueue放入
+//@return 返回w证明是成功的，否则返回ErrPoolOverload(too many goroutines blocked on submit or Nonblocking is set true)、ErrPoolClosed、ErrSubmitTimeout或者ctx.Err()
 //@link https://www.cnblogs.com/yang-2018/p/11133580.html todo 条件变量的巧妙运用
 //@reviser sam@2020-04-17 16:49:15
-func (p *Pool) retrieveWorker() *goWorker {
+func (p *Pool) retrieveWorker(ctx context.Context, task poolTask, timeout time.Duration) (*goWorker, error) {
 	//初始化变量
 	var w *goWorker
 	spawnWorker := func() { //从临时对象池中获取"新"worker
@@ -142,68 +554,265 @@ func (p *Pool) retrieveWorker() *goWorker {
 	}
 	//准备操作workers这个切片了，所以一定要上锁，防止并发问题
 	p.lock.Lock()
+	//Submit检查完状态之后池子有可能被关闭了，加锁之后再检查一次，避免在已关闭的池子上创建新的worker
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		p.lock.Unlock()
+		return nil, ErrPoolClosed
+	}
 
-	w = p.workers.detach()
+	//开启了并发限制时，正在执行任务的worker数量达到限制之后就与池子满载一样处理
+	admitted := p.belowLimit(p.Running() - p.workers.len())
+	//已经有提交者在排队时，新来的不能插队，只能排到它们后面
+	queued := p.waiters.len() > 0
+	//有人排队却没有超过并发限制，说明限制放宽了或者池子有了余量，先让排在前面的用上空闲的worker
+	if admitted && queued {
+		p.admitWaiters()
+		admitted = p.belowLimit(p.Running() - p.workers.len())
+	}
+	if admitted && !queued {
+		w, _ = p.workers.detach().(*goWorker) //容器为空时断言失败，w为nil
+	}
 	if w != nil { //a.取出来那就解锁就好了，直接会结束if分支，进入return w的
 		p.lock.Unlock()
-	} else if p.Running() < p.Cap() { //b.当前无空闲worker但是池子还没有超过限制
+	} else if admitted && !queued && p.Running() < p.Cap() { //b.当前无空闲worker但是池子还没有超过限制
 		p.lock.Unlock()
 		spawnWorker()
 	} else { //c.池子容量已满，新请求等待还是直接打回头，看具体参数设置
+		//c0.开启了任务队列，则放入队列中由正在运行的worker执行完手头的任务之后来消费，队列也满了才算过载
+		if p.tasks.cap() > 0 {
+			if !p.tasks.reserve() {
+				//队列满了，DiscardOldestPolicy丢弃队头最老的任务，为新任务腾出位置(队列中的位置都只是被预留了的话就没有可以丢弃的)
+				if !p.options.discardOldest() {
+					p.lock.Unlock()
+					return nil, ErrPoolOverload
+				}
+				oldest, ok := p.tasks.discardOldest()
+				if !ok {
+					p.lock.Unlock()
+					return nil, ErrPoolOverload
+				}
+				p.pending.done() //被丢弃的任务不会再执行了
+				p.tasks.reserve()
+				atomic.AddUint64(&p.stats.discarded, 1)
+				p.lock.Unlock()
+				oldest.dropped(ErrTaskDiscarded)
+				return nil, nil
+			}
+			p.lock.Unlock()
+			return nil, nil
+		}
 		//c1.任务如果是非阻塞的,则返回nil,即不可以继续再添加了，如果
 		if p.options.Nonblocking {
 			p.lock.Unlock()
-			return nil
+			return nil, ErrPoolOverload
 		}
 		//如果是阻塞的，即非非阻塞的，则不停的循环获取一个空闲worker(前提是未超过 MaxBlockingTasks)
+		//阻塞的任务数已经达到上限时，DiscardOldestPolicy挤掉等待最久的那个提交者，自己顶替它的位置
+		discarded := false
+		if p.options.MaxBlockingTasks != 0 && p.blockingNum >= p.options.MaxBlockingTasks && p.options.discardOldest() {
+			if discarded = p.waiters.discardOldest(); discarded {
+				atomic.AddUint64(&p.stats.discarded, 1)
+			}
+		}
+		wt := p.waiters.newWaiter(task.priority) //重新等待时沿用同一个等待者，保持排队的位置
+		deadline := p.options.waitDeadline(timeout) //重新等待时也不会延长
 	Reentry:
 		//-------------------------
-		//判断提交的任务是否已经超过阻塞限制的个数了
-		if p.options.MaxBlockingTasks != 0 && p.blockingNum >= p.options.MaxBlockingTasks {
+		//判断提交的任务是否已经超过阻塞限制的个数了(被挤掉的提交者还没来得及减少blockingNum，所以顶替者这次不用判断)
+		if !discarded && p.options.MaxBlockingTasks != 0 && p.blockingNum >= p.options.MaxBlockingTasks {
 			p.lock.Unlock()
-			return nil
+			return nil, ErrPoolOverload
 		}
+		discarded = false
 		p.blockingNum++
-		p.cond.Wait() //这里的内涵很深额
+		handed, err := p.waiters.wait(ctx, p.lock, wt, deadline) //这里的内涵很深额
 		p.blockingNum--
-		//条件变量收到通知之后，p.Running()是有可能为0的额
-		if p.Running() == 0 {
+		//ctx被取消或者超时了，或者被新任务挤掉了，放弃等待
+		if err != nil {
 			p.lock.Unlock()
-			spawnWorker()
-			return w
+			return nil, err
+		}
+		//放回的worker直接交到了自己手上
+		if handed != nil {
+			p.lock.Unlock()
+			return handed.(*goWorker), nil
+		}
+		//被Release唤醒的
+		if atomic.LoadInt32(&p.state) == CLOSED {
+			p.lock.Unlock()
+			return nil, ErrPoolClosed
+		}
+        //被Tune扩容或者池子空了唤醒的，继续从items中获取一个空闲的
+		admitted = p.belowLimit(p.Running() - p.workers.len())
+		if admitted {
+			w, _ = p.workers.detach().(*goWorker)
 		}
-        //继续从items中获取一个空闲的
-		w = p.workers.detach()
 		if w == nil {
-			goto Reentry
+			//收到通知之后，p.Running()是有可能为0的额(worker都被清理了)，也可能是Tune扩容了，此时自己开启一个新的worker
+			if !admitted || p.Running() != 0 && p.Running() >= p.Cap() {
+				goto Reentry
+			}
+			p.lock.Unlock()
+			spawnWorker()
+			return w, nil
 		}
 		//-------------------------
 		p.lock.Unlock()
 
 	}
-	return w
+	return w, nil
+}
+
+//将已经在retrieveWorker或者SubmitBatch中预留了位置的任务放入任务队列。
+//预留之后正在运行的worker有可能已经都空闲下来甚至退出了，队列中的任务就没有worker来消费了，
+//所以并发限制以及容量允许的话，取出队头的任务交给空闲的worker或者新开启的worker来执行
+func (p *Pool) enqueue(tasks ...poolTask) {
+	var (
+		workers []*goWorker
+		spawn   int
+	)
+	p.lock.Lock()
+	p.tasks.unreserve(len(tasks))
+	for _, task := range tasks {
+		p.tasks.push(task, task.priority)
+	}
+	for len(workers)+spawn < len(tasks) && p.belowLimit(p.Running()-p.workers.len()) {
+		if w, _ := p.workers.detach().(*goWorker); w != nil {
+			workers = append(workers, w)
+		} else if p.Running() < p.Cap() {
+			p.incRunning() //先预留容量，解锁之后再开启
+			spawn++
+		} else {
+			break
+		}
+	}
+	next := make([]poolTask, 0, len(workers)+spawn)
+	for len(next) < cap(next) {
+		task, _ := p.tasks.pop()
+		next = append(next, task)
+	}
+	p.lock.Unlock()
+	for i := 0; i < spawn; i++ {
+		w := p.workerCache.Get().(*goWorker)
+		w.start()
+		workers = append(workers, w)
+	}
+	for i, w := range workers {
+		w.task <- next[i]
+	}
 }
 
 //将worker放回自由池子中，并回收对应的goroutine
+//如果任务队列中还有积压的任务，则不放回，而是直接返回下一个要执行的任务(即使池子已经关闭了，已经接受的任务也要执行完)
 //@reviser sam@2020-04-18 09:43:44
-func (p *Pool) revertWorker(worker *goWorker) bool {
-	if atomic.LoadInt32(&p.state) == CLOSED || p.Running() > p.Cap() {
-		return false
-	}
-	worker.recycleTime = time.Now()
+func (p *Pool) revertWorker(worker *goWorker) (poolTask, bool) {
 	//上锁
 	p.lock.Lock()
+	//检查队列与放回空闲队列必须在同一次加锁中完成，否则在两者之间入队的任务就没有worker来消费了
+	//超过了并发限制时不再消费队列，留给之后放回的worker(池子关闭了则不管限制，已经接受的任务都要执行完)
+	closed := atomic.LoadInt32(&p.state) == CLOSED
+	admitted := p.belowLimit(p.Running() - p.workers.len() - 1)
+	if closed || admitted {
+		if task, ok := p.tasks.pop(); ok {
+			p.lock.Unlock()
+			return task, true
+		}
+	}
+	if closed || p.Running() > p.Cap() {
+		p.lock.Unlock()
+		return poolTask{}, false
+	}
+	worker.recycleTime = time.Now()
+
+	//有调用者卡在retrieveWorker()中时，直接把worker交给排在最前面的那个，而不是放回空闲队列让大家去抢
+	if admitted && p.waiters.handOff(worker) {
+		p.lock.Unlock()
+		return poolTask{}, true
+	}
 
 	err := p.workers.insert(worker) //items中
 	if err != nil {
+		p.lock.Unlock()
+		return poolTask{}, false
+	}
+	//放回之后正在执行任务的worker少了一个，此时的并发限制有可能已经放宽了，再给排队的提交者一次机会
+	p.admitWaiters()
+	p.lock.Unlock()
+	return poolTask{}, true
+}
+
+//开启了并发限制时，判断正在执行任务的worker数量busy是否低于限制，必须在持有锁的情况下调用
+func (p *Pool) belowLimit(busy int) bool {
+	l := p.options.ConcurrencyLimiter
+	return l == nil || busy < l.Limit()
+}
+
+//开启了并发限制时，计算批量提交的n个任务中最多还能立即执行多少个，必须在持有锁的情况下调用
+func (p *Pool) batchQuota(n int) int {
+	if l := p.options.ConcurrencyLimiter; l != nil {
+		if free := l.Limit() - (p.Running() - p.workers.len()); free < n {
+			n = free
+		}
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+//同时执行任务数的限制
+func (p *Pool) limit() int {
+	limit := p.Cap()
+	if l := p.options.ConcurrencyLimiter; l != nil && l.Limit() < limit {
+		limit = l.Limit()
+	}
+	return limit
+}
+
+//worker因为panic退出或者池子扩容时，任务队列中可能还有积压的任务，而且它们只能由正在运行的worker来消费，
+//所以这里补充一个新的worker来接着执行。与批量提交一样受并发限制以及容量的约束，并在解锁之前预留容量，
+//队列为空或者不能再开启新的worker时返回false
+func (p *Pool) drainTasks() bool {
+	p.lock.Lock()
+	if p.Running() >= p.Cap() || !p.belowLimit(p.Running()-p.workers.len()) {
 		p.lock.Unlock()
 		return false
 	}
+	task, ok := p.tasks.pop()
+	if ok {
+		p.incRunning()
+	}
+	p.lock.Unlock()
+	if ok {
+		w := p.workerCache.Get().(*goWorker)
+		w.start()
+		w.task <- task
+	}
+	return ok
+}
 
-	//通知调用者卡在retrieveWorker()中获取的w，告诉它现在有一个可用的worker要被放入到空闲工作队列中了
-	p.cond.Signal()
+//worker因为panic退出之后池子有了余量，叫醒排在最前面的提交者去开启新的worker，
+//否则它要等到下一次清理过期worker时才会被唤醒
+func (p *Pool) wakeWaiter() {
+	p.lock.Lock()
+	p.admitWaiters()
 	p.lock.Unlock()
-	return true
+}
+
+//没有超过并发限制却有提交者在排队(并发限制放宽了，或者池子有了余量)时，它们并不会自己醒来：
+//把空闲的worker直接交给排在最前面的等待者，没有空闲的worker但池子还有余量的话，叫醒一个去开启新的worker，
+//必须在持有锁的情况下调用
+func (p *Pool) admitWaiters() {
+	for p.waiters.len() > 0 && p.belowLimit(p.Running()-p.workers.len()) {
+		if w := p.workers.detach(); w != nil {
+			p.waiters.handOff(w)
+			continue
+		}
+		if p.Running() < p.Cap() {
+			p.waiters.signal()
+		}
+		return
+	}
 }
 
 // ---------------------------------------------------------------------------
@@ -224,6 +833,26 @@ func NewPool(size int, options ...Option) (*Pool, error) {
 	} else if expiry == 0 {
 		opts.ExpiryDuration = DefaultCleanIntervalTime
 	}
+    //至少保留的空闲worker数量
+	if opts.MinIdleWorkers < 0 {
+		return nil, ErrInvalidMinIdleWorkers
+	}
+    //任务队列的长度
+	if opts.QueueSize < 0 {
+		return nil, ErrInvalidQueueSize
+	}
+    //阻塞等待空闲worker的最长时间
+	if opts.MaxWaitDuration < 0 {
+		return nil, ErrInvalidMaxWaitDuration
+	}
+    //自动扩缩容的配置
+	if opts.Autoscaler != nil {
+		config := *opts.Autoscaler //拷贝一份再设置默认值，避免修改调用方的配置
+		if err := config.validate(); err != nil {
+			return nil, err
+		}
+		opts.Autoscaler = &config
+	}
     //日志处理驱动的设置
 	if opts.Logger == nil {
 		opts.Logger = defaultLogger
@@ -232,6 +861,9 @@ func NewPool(size int, options ...Option) (*Pool, error) {
 	p := &Pool{
 		capacity: int32(size),
 		lock:     internal.NewSpinLock(),
+		waiters:  newWaitQueue(opts.PriorityAging),
+		tasks:    newTaskQueue[poolTask](opts.QueueSize, opts.PriorityAging),
+		stats:    new(poolStats),
 		options:  opts,
 	}
 	//(3)池子需要动态配置的几个属性字段
@@ -239,19 +871,15 @@ func NewPool(size int, options ...Option) (*Pool, error) {
 	p.workerCache.New = func() interface{} {
 		return &goWorker{
 			pool: p,
-			task: make(chan func(), workerChanCap),//make(chan func(),1)
+			task: make(chan poolTask, workerChanCap),//make(chan poolTask,1)
 		}
 	}
 	//在初始化Pool时是否对内存进行预分配
-	if p.options.PreAlloc {
-		p.workers = newWorkerArray(loopQueueType, size)
-	} else {
-		p.workers = newWorkerArray(stackType, 0)
-	}
-	//初始化条件变量
-	p.cond = sync.NewCond(p.lock)
+	p.workers = p.newWorkerArray()
 	//(4)专门启动一个定时任务以及启动定期清理过期worker任务，独立goroutine运行
-	go p.periodicallyPurge()
+	p.startBackground()
+	//(5)开启了预分配时预热worker
+	p.warmupPreAlloc()
 
 	return p, nil
 }
\ No newline at end of file
@@ -23,6 +23,7 @@
 package ants
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -30,17 +31,21 @@ import (
 	"github.com/panjf2000/ants/v2/internal"
 )
 
-// PoolWithFunc accepts the tasks from client,
+// PoolWithFuncOf accepts the tasks of type T from client,
 // it limits the total of goroutines to a given number by recycling goroutines.
-type PoolWithFunc struct {
+type PoolWithFuncOf[T any] struct {
 	// capacity of the pool.
 	capacity int32
 
-	// running is the number of the currently running goroutines.
+	// running is the number of the currently alive workers (goroutines), including the idle ones,
+	// see inflight for the number of the tasks being executed.
 	running int32
 
+	// inflight is the number of the tasks being executed, while running also includes the idle workers.
+	inflight int32
+
 	// workers is a slice that store the available workers.
-	workers []*goWorkerWithFunc
+	workers workerArray
 
 	// state is used to notice the pool to closed itself.
 	state int32
@@ -48,11 +53,11 @@ type PoolWithFunc struct {
 	// lock for synchronous operation.
 	lock sync.Locker
 
-	// cond for waiting to get a idle worker.
-	cond *sync.Cond
+	// waiters is the queue of invokers waiting to get an idle worker.
+	waiters waitQueue
 
 	// poolFunc is the function for processing tasks.
-	poolFunc func(interface{})
+	poolFunc func(context.Context, T)
 
 	// workerCache speeds up the obtainment of the an usable worker in function:retrieveWorker.
 	workerCache sync.Pool
@@ -60,34 +65,59 @@ type PoolWithFunc struct {
 	// blockingNum is the number of the goroutines already been blocked on pool.Submit, protected by pool.lock
 	blockingNum int
 
+	// tasks buffers the invocations when the pool is full, protected by pool.lock.
+	tasks taskQueue[funcTask[T]]
+
+	// stats holds the cumulative statistics of this pool.
+	stats *poolStats
+
+	// dropped is called outside the lock with the args of an accepted invocation that will never run,
+	// either discarded by the rejection policy or handed to the RejectionHandler,
+	// so that whoever waits for it can finish with err, it's set by PoolWithFuncResult.
+	dropped func(args T, err error)
+
+	// unwrap converts args into what the user callbacks (hooks and RejectionHandler) receive,
+	// it's set by PoolWithFuncResult to hide its internal call, nil means args itself.
+	unwrap func(args T) interface{}
+
+	// pending is the number of tasks accepted but not finished yet, used by Wait.
+	pending pendingTasks
+
+	// stopBackground stops the background goroutines purging expired workers and autoscaling,
+	// protected by pool.lock.
+	stopBackground context.CancelFunc
+
 	options *Options
 }
 
-// periodicallyPurge clears expired workers periodically.
-func (p *PoolWithFunc) periodicallyPurge() {
+// PoolWithFunc is a PoolWithFuncOf whose tasks can be of any type.
+type PoolWithFunc = PoolWithFuncOf[interface{}]
+
+// funcTask is an invocation of PoolWithFuncOf, since is the time it was invoked.
+// It's also what a worker receives, a funcTask without ctx tells the worker to exit.
+type funcTask[T any] struct {
+	ctx      context.Context
+	args     T
+	since    time.Time
+	priority int
+}
+
+// periodicallyPurge clears expired workers periodically until ctx is done.
+func (p *PoolWithFuncOf[T]) periodicallyPurge(ctx context.Context) {
 	heartbeat := time.NewTicker(p.options.ExpiryDuration)
 	defer heartbeat.Stop()
 
-	var expiredWorkers []*goWorkerWithFunc
-	for range heartbeat.C {
-		if atomic.LoadInt32(&p.state) == CLOSED {
-			break
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
 		}
-		currentTime := time.Now()
+
 		p.lock.Lock()
-		idleWorkers := p.workers
-		n := len(idleWorkers)
-		var i int
-		for i = 0; i < n && currentTime.Sub(idleWorkers[i].recycleTime) > p.options.ExpiryDuration; i++ {
-		}
-		expiredWorkers = append(expiredWorkers[:0], idleWorkers[:i]...)
-		if i > 0 {
-			m := copy(idleWorkers, idleWorkers[i:])
-			for i = m; i < n; i++ {
-				idleWorkers[i] = nil
-			}
-			p.workers = idleWorkers[:m]
-		}
+		expiredWorkers := p.keepMinIdle(p.workers.retrieveExpiry(p.options.ExpiryDuration))
+		// Let the waiting invokers use the idle workers at the latest here if the concurrency limit has grown.
+		p.admitWaiters()
 		p.lock.Unlock()
 
 		// Notify obsolete workers to stop.
@@ -95,21 +125,47 @@ func (p *PoolWithFunc) periodicallyPurge() {
 		// may be blocking and may consume a lot of time if many workers
 		// are located on non-local CPUs.
 		for i, w := range expiredWorkers {
-			w.args <- nil
+			p.options.Hooks.workerExpire(w.workerID())
+			w.stop()
 			expiredWorkers[i] = nil
 		}
+		atomic.AddUint64(&p.stats.workersPurged, uint64(len(expiredWorkers)))
 
 		// There might be a situation that all workers have been cleaned up(no any worker is running)
-		// while some invokers still get stuck in "p.cond.Wait()",
+		// while some invokers still get stuck in "p.waiters.wait()",
 		// then it ought to wakes all those invokers.
 		if p.Running() == 0 {
-			p.cond.Broadcast()
+			p.lock.Lock()
+			p.waiters.broadcast()
+			p.lock.Unlock()
 		}
 	}
 }
 
 // NewPoolWithFunc generates an instance of ants pool with a specific function.
 func NewPoolWithFunc(size int, pf func(interface{}), options ...Option) (*PoolWithFunc, error) {
+	return NewPoolWithFuncOf(size, pf, options...)
+}
+
+// NewPoolWithFuncContext generates an instance of ants pool with a specific function,
+// which receives the context passed to InvokeContext (or context.Background() for Invoke).
+func NewPoolWithFuncContext(size int, pf func(context.Context, interface{}), options ...Option) (*PoolWithFunc, error) {
+	return NewPoolWithFuncOfContext(size, pf, options...)
+}
+
+// NewPoolWithFuncOf generates an instance of ants pool with a specific function taking arguments of type T.
+func NewPoolWithFuncOf[T any](size int, pf func(T), options ...Option) (*PoolWithFuncOf[T], error) {
+	if pf == nil {
+		return nil, ErrLackPoolFunc
+	}
+	return NewPoolWithFuncOfContext(size, func(_ context.Context, args T) {
+		pf(args)
+	}, options...)
+}
+
+// NewPoolWithFuncOfContext is like NewPoolWithFuncOf, but the function also receives
+// the context passed to InvokeContext (or context.Background() for Invoke).
+func NewPoolWithFuncOfContext[T any](size int, pf func(context.Context, T), options ...Option) (*PoolWithFuncOf[T], error) {
 	if size <= 0 {
 		return nil, ErrInvalidPoolSize
 	}
@@ -126,162 +182,793 @@ func NewPoolWithFunc(size int, pf func(interface{}), options ...Option) (*PoolWi
 		opts.ExpiryDuration = DefaultCleanIntervalTime
 	}
 
+	if opts.MinIdleWorkers < 0 {
+		return nil, ErrInvalidMinIdleWorkers
+	}
+
+	if opts.QueueSize < 0 {
+		return nil, ErrInvalidQueueSize
+	}
+
+	if opts.MaxWaitDuration < 0 {
+		return nil, ErrInvalidMaxWaitDuration
+	}
+
+	if opts.Autoscaler != nil {
+		// Copy the config before filling in the defaults, so that the caller's one isn't modified.
+		config := *opts.Autoscaler
+		if err := config.validate(); err != nil {
+			return nil, err
+		}
+		opts.Autoscaler = &config
+	}
+
 	if opts.Logger == nil {
 		opts.Logger = defaultLogger
 	}
 
-	p := &PoolWithFunc{
+	p := &PoolWithFuncOf[T]{
 		capacity: int32(size),
 		poolFunc: pf,
 		lock:     internal.NewSpinLock(),
+		waiters:  newWaitQueue(opts.PriorityAging),
+		tasks:    newTaskQueue[funcTask[T]](opts.QueueSize, opts.PriorityAging),
+		stats:    new(poolStats),
 		options:  opts,
 	}
 	p.workerCache.New = func() interface{} {
-		return &goWorkerWithFunc{
+		return &goWorkerWithFunc[T]{
 			pool: p,
-			args: make(chan interface{}, workerChanCap),
+			args: make(chan funcTask[T], workerChanCap),
 		}
 	}
-	if p.options.PreAlloc {
-		p.workers = make([]*goWorkerWithFunc, 0, size)
-	}
-	p.cond = sync.NewCond(p.lock)
+	p.workers = p.newWorkerArray()
 
 	// Start a goroutine to clean up expired workers periodically.
-	go p.periodicallyPurge()
+	p.startBackground()
+
+	// Spawn the workers eagerly if PreAlloc is set.
+	p.warmupPreAlloc()
 
 	return p, nil
 }
 
 //---------------------------------------------------------------------------
 
-// Invoke submits a task to pool.
-func (p *PoolWithFunc) Invoke(args interface{}) error {
+// Invoke submits a task to pool, args can be any value including nil.
+func (p *PoolWithFuncOf[T]) Invoke(args T) error {
+	return p.InvokeContext(context.Background(), args)
+}
+
+// InvokeContext submits a task to pool like Invoke, but gives up waiting for an idle worker
+// and returns ctx.Err() once ctx is done, ctx is also passed to the pool function.
+func (p *PoolWithFuncOf[T]) InvokeContext(ctx context.Context, args T) error {
+	return p.invoke(ctx, args, 0, 0)
+}
+
+// InvokeBatch submits a batch of tasks, taking as many idle workers as possible and reserving the rest
+// of the capacity for new workers under a single lock acquisition, then filling the task queue if enabled.
+// The remaining tasks are submitted one by one following the blocking/nonblocking configuration.
+// It returns the number of tasks accepted (i.e. for which Invoke would have returned nil),
+// and stops at the first task failing to be submitted, returning its error.
+func (p *PoolWithFuncOf[T]) InvokeBatch(args []T) (int, error) {
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		return 0, ErrPoolClosed
+	}
+	ctx, now := context.Background(), time.Now()
+	// Count the batch in first like invoke, the ones left to invoke are subtracted later.
+	p.pending.add(len(args))
+	p.lock.Lock()
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		p.lock.Unlock()
+		p.pending.add(-len(args))
+		return 0, ErrPoolClosed
+	}
+	var (
+		workers []*goWorkerWithFunc[T]
+		spawn   int
+		queued  int
+	)
+	// The whole batch mustn't jump ahead of the invokers already waiting in line.
+	if p.waiters.len() == 0 {
+		quota := p.batchQuota(len(args))
+		for len(workers) < quota {
+			w, _ := p.workers.detach().(*goWorkerWithFunc[T])
+			if w == nil {
+				break
+			}
+			workers = append(workers, w)
+		}
+		if spawn = quota - len(workers); spawn > p.Cap()-p.Running() {
+			spawn = p.Cap() - p.Running()
+		}
+		if spawn > 0 {
+			// Reserve the capacity, the workers are started after unlocking.
+			atomic.AddInt32(&p.running, int32(spawn))
+		} else {
+			spawn = 0
+		}
+		// Reserve the slots in the task queue for the rest, the ones not fitting are invoked one by one below.
+		for range args[len(workers)+spawn:] {
+			if !p.tasks.reserve() {
+				break
+			}
+			queued++
+		}
+	}
+	p.lock.Unlock()
+	for i := 0; i < spawn; i++ {
+		w := p.workerCache.Get().(*goWorkerWithFunc[T])
+		w.start()
+		workers = append(workers, w)
+	}
+	h := p.options.Hooks.OnSubmit
+	for i, w := range workers {
+		p.stats.taskSubmitted(0)
+		if h != nil {
+			h(p.userArgs(args[i]))
+		}
+		w.args <- funcTask[T]{ctx, args[i], now, 0}
+	}
+	n := len(workers)
+	if queued > 0 {
+		// Count and report the tasks before enqueuing them, otherwise a worker might have run them already.
+		tasks := make([]funcTask[T], queued)
+		for i, a := range args[n : n+queued] {
+			p.stats.taskSubmitted(0)
+			if h != nil {
+				h(p.userArgs(a))
+			}
+			tasks[i] = funcTask[T]{ctx, a, now, 0}
+		}
+		p.enqueue(tasks...)
+	}
+	n += queued
+	p.pending.add(n - len(args))
+	for _, a := range args[n:] {
+		if err := p.invoke(ctx, a, 0, 0); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// InvokeTimeout submits a task to pool like Invoke, but gives up waiting for an idle worker
+// and returns ErrSubmitTimeout after timeout, a timeout <= 0 is only limited by WithMaxWaitDuration.
+func (p *PoolWithFuncOf[T]) InvokeTimeout(args T, timeout time.Duration) error {
+	return p.invoke(context.Background(), args, 0, timeout)
+}
+
+// InvokeWithPriority submits a task to pool with the given priority.
+// When the pool is saturated, blocked invokers and queued tasks are served from the highest
+// priority to the lowest, first come first served within the same priority, Invoke uses 0.
+// With WithPriorityAging, the longer a task waits the higher its priority gets.
+func (p *PoolWithFuncOf[T]) InvokeWithPriority(args T, priority int) error {
+	return p.invoke(context.Background(), args, priority, 0)
+}
+
+// invoke submits a task to pool, timeout limits the time to wait for an idle worker,
+// 0 means it's only limited by WithMaxWaitDuration.
+func (p *PoolWithFuncOf[T]) invoke(ctx context.Context, args T, priority int, timeout time.Duration) error {
 	if atomic.LoadInt32(&p.state) == CLOSED {
 		return ErrPoolClosed
 	}
-	var w *goWorkerWithFunc
-	if w = p.retrieveWorker(); w == nil {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// Count the task in first, otherwise a queued task might finish before being counted.
+	p.pending.add(1)
+	// w is nil if a slot has been reserved for the invocation in the task queue.
+	task := funcTask[T]{ctx, args, time.Now(), priority}
+	w, err := p.retrieveWorker(task, timeout)
+	if err != nil {
+		p.pending.done()
+	}
+	switch err {
+	case nil:
+	case ErrPoolOverload:
+		return p.reject(ctx, args)
+	case ErrSubmitTimeout:
+		// Timing out isn't an overload, it's counted separately and bypasses the rejection policy.
+		atomic.AddUint64(&p.stats.timedOut, 1)
+		return err
+	case ErrTaskDiscarded:
+		// Discarded by a newer invocation while waiting, which has been counted already.
+		// The invocation was never accepted, so the invoker still gets ErrPoolOverload.
 		return ErrPoolOverload
+	default:
+		return err
+	}
+	// Count and report the task before it's visible to the workers,
+	// otherwise its OnTaskStart/OnTaskEnd might come first.
+	p.stats.taskSubmitted(priority)
+	// Check the hook first to avoid boxing args when it isn't set.
+	if h := p.options.Hooks.OnSubmit; h != nil {
+		h(p.userArgs(args))
+	}
+	if w != nil {
+		w.args <- task
+	} else {
+		p.enqueue(task)
 	}
-	w.args <- args
 	return nil
 }
 
-// Running returns the number of the currently running goroutines.
-func (p *PoolWithFunc) Running() int {
+// reject handles an invocation according to the rejection policy when the pool is saturated.
+func (p *PoolWithFuncOf[T]) reject(ctx context.Context, args T) error {
+	atomic.AddUint64(&p.stats.rejected, 1)
+	if h := p.options.Hooks.OnReject; h != nil {
+		h(p.userArgs(args))
+	}
+	if h := p.options.RejectionHandler; h != nil {
+		h(p.userArgs(args))
+		// The invocation is accepted from the invoker's point of view, but it won't be run by the pool.
+		if p.dropped != nil {
+			p.dropped(args, ErrPoolOverload)
+		}
+		return nil
+	}
+	switch p.options.RejectionPolicy {
+	case CallerRunsPolicy:
+		p.poolFunc(ctx, args)
+		return nil
+	case DiscardPolicy, DiscardOldestPolicy:
+		// There is no older invocation to discard if we get here, so discard the new one.
+		atomic.AddUint64(&p.stats.discarded, 1)
+		p.discard(args)
+		return nil
+	default:
+		return ErrPoolOverload
+	}
+}
+
+// Running returns the number of the currently running goroutines, which is the same as Alive
+// and includes the idle workers, see Busy for the number of workers running tasks.
+func (p *PoolWithFuncOf[T]) Running() int {
 	return int(atomic.LoadInt32(&p.running))
 }
 
-// Free returns a available goroutines to work.
-func (p *PoolWithFunc) Free() int {
-	return p.Cap() - p.Running()
+// Alive returns the number of alive workers, both busy and idle.
+func (p *PoolWithFuncOf[T]) Alive() int {
+	return p.Running()
+}
+
+// Busy returns the number of workers running tasks.
+func (p *PoolWithFuncOf[T]) Busy() int {
+	return int(atomic.LoadInt32(&p.inflight))
+}
+
+// Idle returns the number of workers alive but parked in the worker queue waiting for tasks.
+func (p *PoolWithFuncOf[T]) Idle() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.workers.len()
+}
+
+// QueueLen returns the number of invocations waiting in the task queue.
+func (p *PoolWithFuncOf[T]) QueueLen() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.tasks.len()
+}
+
+// Discarded returns the number of invocations discarded by the rejection policy.
+func (p *PoolWithFuncOf[T]) Discarded() int {
+	return int(atomic.LoadUint64(&p.stats.discarded))
+}
+
+// Wait blocks until all the tasks submitted so far, including the queued ones, have finished.
+// Tasks submitted while waiting are waited for as well, so it may never return if the pool keeps busy.
+func (p *PoolWithFuncOf[T]) Wait() {
+	_ = p.pending.wait(context.Background())
+}
+
+// WaitContext is like Wait, but gives up waiting and returns ctx.Err() once ctx is done.
+func (p *PoolWithFuncOf[T]) WaitContext(ctx context.Context) error {
+	return p.pending.wait(ctx)
+}
+
+// Stats returns a snapshot of the statistics of this pool.
+func (p *PoolWithFuncOf[T]) Stats() PoolStats {
+	ps := PoolStats{
+		Capacity: p.Cap(),
+		Limit:    p.limit(),
+		Running:  p.Running(),
+		Busy:     p.Busy(),
+	}
+	p.lock.Lock()
+	ps.Idle = p.workers.len()
+	ps.Waiting = p.blockingNum
+	ps.Queued = p.tasks.len()
+	waiting := make(map[int]int)
+	p.waiters.countPriorities(waiting)
+	p.tasks.countPriorities(waiting)
+	p.lock.Unlock()
+	p.stats.load(&ps, waiting)
+	return ps
+}
+
+// Free returns the number of tasks that can still run concurrently, i.e. the capacity minus the busy workers,
+// idle workers don't take up the capacity.
+func (p *PoolWithFuncOf[T]) Free() int {
+	return p.Cap() - p.Busy()
 }
 
 // Cap returns the capacity of this pool.
-func (p *PoolWithFunc) Cap() int {
+func (p *PoolWithFuncOf[T]) Cap() int {
 	return int(atomic.LoadInt32(&p.capacity))
 }
 
-// Tune changes the capacity of this pool.
-func (p *PoolWithFunc) Tune(size int) {
-	if size < 0 || p.Cap() == size || p.options.PreAlloc {
-		return
+// Tune changes the capacity of this pool and applies it immediately, it returns the old capacity.
+// A PreAlloc pool reallocates its loop queue under the lock.
+// Shrinking retires the surplus idle workers (the least recently used ones) at once,
+// while the running ones exit after finishing their tasks.
+// Growing wakes up as many waiting invokers as the added capacity,
+// and spawns workers for the invocations in the task queue.
+func (p *PoolWithFuncOf[T]) Tune(size int) int {
+	if size < 0 {
+		return p.Cap()
+	}
+	p.lock.Lock()
+	oldCap := p.Cap()
+	if oldCap == size {
+		p.lock.Unlock()
+		return oldCap
 	}
 	atomic.StoreInt32(&p.capacity, int32(size))
+	var surplus []worker
+	if q, ok := p.workers.(*loopQueue); ok {
+		surplus = q.resize(size)
+	}
+	// The workers in surplus haven't exited yet, so they are still counted by Running().
+	if n := p.Running() - len(surplus) - size; n > 0 {
+		surplus = append(surplus, p.workers.trim(n)...)
+	}
+	for n := size - oldCap; n > 0 && p.waiters.len() > 0; n-- {
+		p.waiters.signal()
+	}
+	p.lock.Unlock()
+	// Like purging, notifying the workers must be outside the p.lock.
+	for _, w := range surplus {
+		w.stop()
+	}
+	for n := size - oldCap; n > 0 && p.drainTasks(); n-- {
+	}
+	return oldCap
 }
 
 // Release Closes this pool.
-func (p *PoolWithFunc) Release() {
+func (p *PoolWithFuncOf[T]) Release() {
 	atomic.StoreInt32(&p.state, CLOSED)
 	p.lock.Lock()
-	idleWorkers := p.workers
-	for _, w := range idleWorkers {
-		w.args <- nil
-	}
-	p.workers = nil
+	p.stopBackground()
+	p.workers.reset()
+	// Wake up all the invokers stuck in "p.waiters.wait()", they will get ErrPoolClosed.
+	p.waiters.broadcast()
 	p.lock.Unlock()
 }
 
-// Reboot reboots a released pool.
-func (p *PoolWithFunc) Reboot() {
+// Shutdown closes this pool like Release and waits for all running workers to exit,
+// after they have run the invocations accepted into the task queue.
+// If ctx is done before that, it returns the number of unfinished invocations, both running and queued,
+// which will still be run afterwards, and ctx.Err().
+func (p *PoolWithFuncOf[T]) Shutdown(ctx context.Context) (int, error) {
+	p.Release()
+	heartbeat := time.NewTicker(shutdownPollInterval)
+	defer heartbeat.Stop()
+	for p.Running() > 0 {
+		select {
+		case <-ctx.Done():
+			return p.pending.len(), ctx.Err()
+		case <-heartbeat.C:
+		}
+	}
+	return 0, nil
+}
+
+// ReleaseTimeout is like Shutdown but waits for at most timeout.
+func (p *PoolWithFuncOf[T]) ReleaseTimeout(timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return p.Shutdown(ctx)
+}
+
+// Reboot reboots a released pool, making it behave like a newly created one:
+// the worker container is rebuilt with the current capacity, since a released loop queue can't hold workers,
+// the statistics are cleared, the purging goroutine is restarted and a PreAlloc pool is warmed up again.
+// blockingNum isn't reset since the invokers woken up by Release will decrease it by themselves.
+func (p *PoolWithFuncOf[T]) Reboot() {
 	if atomic.CompareAndSwapInt32(&p.state, CLOSED, OPENED) {
-		go p.periodicallyPurge()
+		p.lock.Lock()
+		p.workers = p.newWorkerArray()
+		p.stats.reset()
+		p.startBackground()
+		p.lock.Unlock()
+		p.warmupPreAlloc()
+	}
+}
+
+// Warmup spawns n workers eagerly and puts them into the idle worker queue, sparing the tasks submitted later
+// the cost of starting goroutines and growing their stacks.
+// It's limited by the capacity and returns the number of workers spawned.
+func (p *PoolWithFuncOf[T]) Warmup(n int) int {
+	if n <= 0 || atomic.LoadInt32(&p.state) == CLOSED {
+		return 0
+	}
+	p.lock.Lock()
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		p.lock.Unlock()
+		return 0
+	}
+	if free := p.Cap() - p.Running(); n > free {
+		n = free
+	}
+	if n <= 0 {
+		p.lock.Unlock()
+		return 0
+	}
+	// Reserve the capacity, the workers are started after unlocking.
+	atomic.AddInt32(&p.running, int32(n))
+	p.lock.Unlock()
+	for i := 0; i < n; i++ {
+		w := p.workerCache.Get().(*goWorkerWithFunc[T])
+		w.start()
+		// Put it back like a worker finishing a task, it's used at once
+		// if there are queued tasks or waiting invokers.
+		if task, ok := p.revertWorker(w); !ok {
+			w.stop()
+		} else if task.ctx != nil {
+			w.args <- task
+		}
+	}
+	return n
+}
+
+// warmupPreAlloc spawns workers eagerly if PreAlloc is set,
+// MinIdleWorkers of them if it's set, otherwise GOMAXPROCS, both limited by the capacity.
+func (p *PoolWithFuncOf[T]) warmupPreAlloc() {
+	if p.options.PreAlloc {
+		p.Warmup(p.options.preAllocWorkers())
+	}
+}
+
+// keepMinIdle keeps the most recently reverted ones of the expired workers, so that there are
+// at least MinIdleWorkers idle workers. The kept workers are treated as just reverted,
+// and the rest to be purged are returned, it must be called with pool.lock held.
+func (p *PoolWithFuncOf[T]) keepMinIdle(expired []worker) []worker {
+	keep := p.options.MinIdleWorkers - p.workers.len()
+	if keep <= 0 {
+		return expired
+	}
+	if keep > len(expired) {
+		keep = len(expired)
+	}
+	now := time.Now()
+	for _, w := range expired[len(expired)-keep:] {
+		w.(*goWorkerWithFunc[T]).recycleTime = now
+		_ = p.workers.insert(w)
+	}
+	return expired[:len(expired)-keep]
+}
+
+// newWorkerArray creates the container of idle workers according to PreAlloc.
+func (p *PoolWithFuncOf[T]) newWorkerArray() workerArray {
+	if p.options.PreAlloc {
+		return newWorkerArray(loopQueueType, p.Cap())
+	}
+	return newWorkerArray(stackType, 0)
+}
+
+// startBackground starts a goroutine to clean up expired workers periodically,
+// and another one to autoscale the capacity if it's configured.
+func (p *PoolWithFuncOf[T]) startBackground() {
+	var ctx context.Context
+	ctx, p.stopBackground = context.WithCancel(context.Background())
+	go p.periodicallyPurge(ctx)
+	if p.options.Autoscaler != nil {
+		go autoscale(ctx, p, p.options.Autoscaler, p.stats, p.options.Logger)
 	}
 }
 
 //---------------------------------------------------------------------------
 
 // incRunning increases the number of the currently running goroutines.
-func (p *PoolWithFunc) incRunning() {
+func (p *PoolWithFuncOf[T]) incRunning() {
 	atomic.AddInt32(&p.running, 1)
 }
 
 // decRunning decreases the number of the currently running goroutines.
-func (p *PoolWithFunc) decRunning() {
+func (p *PoolWithFuncOf[T]) decRunning() {
 	atomic.AddInt32(&p.running, -1)
 }
 
-// retrieveWorker returns a available worker to run the tasks.
-func (p *PoolWithFunc) retrieveWorker() *goWorkerWithFunc {
-	var w *goWorkerWithFunc
+// retrieveWorker returns a available worker to run the tasks,
+// or ErrPoolOverload/ErrPoolClosed/ErrSubmitTimeout/ctx.Err() if it fails to get one.
+// When the pool is full and the task queue is enabled, a slot is reserved for the task in the queue
+// and both of the return values are nil, the caller puts the task into it by enqueue later.
+func (p *PoolWithFuncOf[T]) retrieveWorker(task funcTask[T], timeout time.Duration) (*goWorkerWithFunc[T], error) {
+	var w *goWorkerWithFunc[T]
 	spawnWorker := func() {
-		w = p.workerCache.Get().(*goWorkerWithFunc)
+		w = p.workerCache.Get().(*goWorkerWithFunc[T])
 		w.run()
 	}
 
 	p.lock.Lock()
-	idleWorkers := p.workers
-	n := len(idleWorkers) - 1
-	if n >= 0 {
-		w = idleWorkers[n]
-		idleWorkers[n] = nil
-		p.workers = idleWorkers[:n]
+	// The pool may be closed after Invoke checked its state.
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		p.lock.Unlock()
+		return nil, ErrPoolClosed
+	}
+	// With a concurrency limiter, it's handled as the pool is full once the busy workers reach the limit.
+	admitted := p.belowLimit(p.Running() - p.workers.len())
+	// Newcomers must not jump ahead of the invokers already waiting in line.
+	queued := p.waiters.len() > 0
+	// Invokers waiting while the pool is below the concurrency limit means the limit has grown
+	// or the pool has room again, let the ones ahead use the idle workers first.
+	if admitted && queued {
+		p.admitWaiters()
+		admitted = p.belowLimit(p.Running() - p.workers.len())
+	}
+	if admitted && !queued {
+		w, _ = p.workers.detach().(*goWorkerWithFunc[T]) // w is nil if there is no idle worker.
+	}
+	if w != nil {
 		p.lock.Unlock()
-	} else if p.Running() < p.Cap() {
+	} else if admitted && !queued && p.Running() < p.Cap() {
 		p.lock.Unlock()
 		spawnWorker()
 	} else {
+		if p.tasks.cap() > 0 {
+			if !p.tasks.reserve() {
+				// The queue is full, DiscardOldestPolicy makes room by dropping its head,
+				// unless all the slots are only reserved.
+				if !p.options.discardOldest() {
+					p.lock.Unlock()
+					return nil, ErrPoolOverload
+				}
+				oldest, ok := p.tasks.discardOldest()
+				if !ok {
+					p.lock.Unlock()
+					return nil, ErrPoolOverload
+				}
+				p.pending.done() // The discarded task will never run.
+				p.tasks.reserve()
+				atomic.AddUint64(&p.stats.discarded, 1)
+				p.lock.Unlock()
+				p.discard(oldest.args)
+				return nil, nil
+			}
+			p.lock.Unlock()
+			return nil, nil
+		}
 		if p.options.Nonblocking {
 			p.lock.Unlock()
-			return nil
+			return nil, ErrPoolOverload
 		}
+		// DiscardOldestPolicy kicks out the longest waiting invoker when reaching MaxBlockingTasks.
+		discarded := false
+		if p.options.MaxBlockingTasks != 0 && p.blockingNum >= p.options.MaxBlockingTasks && p.options.discardOldest() {
+			if discarded = p.waiters.discardOldest(); discarded {
+				atomic.AddUint64(&p.stats.discarded, 1)
+			}
+		}
+		// Waiting again after a wakeup reuses the waiter, keeping its place in line.
+		wt := p.waiters.newWaiter(task.priority)
+		// Waiting again doesn't extend the deadline.
+		deadline := p.options.waitDeadline(timeout)
 	Reentry:
-		if p.options.MaxBlockingTasks != 0 && p.blockingNum >= p.options.MaxBlockingTasks {
+		// The discarded invoker hasn't decreased blockingNum yet, so skip the check once after discarding.
+		if !discarded && p.options.MaxBlockingTasks != 0 && p.blockingNum >= p.options.MaxBlockingTasks {
 			p.lock.Unlock()
-			return nil
+			return nil, ErrPoolOverload
 		}
+		discarded = false
 		p.blockingNum++
-		p.cond.Wait()
+		handed, err := p.waiters.wait(task.ctx, p.lock, wt, deadline)
 		p.blockingNum--
-		if p.Running() == 0 {
+		if err != nil {
 			p.lock.Unlock()
-			spawnWorker()
-			return w
+			return nil, err
 		}
-		l := len(p.workers) - 1
-		if l < 0 {
-			goto Reentry
+		// A reverted worker was handed over directly.
+		if handed != nil {
+			p.lock.Unlock()
+			return handed.(*goWorkerWithFunc[T]), nil
+		}
+		if atomic.LoadInt32(&p.state) == CLOSED {
+			p.lock.Unlock()
+			return nil, ErrPoolClosed
+		}
+		admitted = p.belowLimit(p.Running() - p.workers.len())
+		if admitted {
+			w, _ = p.workers.detach().(*goWorkerWithFunc[T])
+		}
+		if w == nil {
+			// All workers may have been purged, or the pool may have been grown by Tune,
+			// then spawn a new worker instead of waiting again.
+			if !admitted || p.Running() != 0 && p.Running() >= p.Cap() {
+				goto Reentry
+			}
+			p.lock.Unlock()
+			spawnWorker()
+			return w, nil
 		}
-		w = p.workers[l]
-		p.workers[l] = nil
-		p.workers = p.workers[:l]
 		p.lock.Unlock()
 	}
-	return w
+	return w, nil
+}
+
+// enqueue puts the tasks whose slots have been reserved by retrieveWorker or InvokeBatch into the task queue.
+// The running workers may have become idle or even exited since the reservation, leaving nobody
+// to consume the queue, so the head of the queue is handed to idle or new workers
+// as far as the concurrency limit and the capacity allow.
+func (p *PoolWithFuncOf[T]) enqueue(tasks ...funcTask[T]) {
+	var (
+		workers []*goWorkerWithFunc[T]
+		spawn   int
+	)
+	p.lock.Lock()
+	p.tasks.unreserve(len(tasks))
+	for _, task := range tasks {
+		p.tasks.push(task, task.priority)
+	}
+	for len(workers)+spawn < len(tasks) && p.belowLimit(p.Running()-p.workers.len()) {
+		if w, _ := p.workers.detach().(*goWorkerWithFunc[T]); w != nil {
+			workers = append(workers, w)
+		} else if p.Running() < p.Cap() {
+			// Reserve the capacity, the worker is started after unlocking.
+			p.incRunning()
+			spawn++
+		} else {
+			break
+		}
+	}
+	next := make([]funcTask[T], 0, len(workers)+spawn)
+	for len(next) < cap(next) {
+		task, _ := p.tasks.pop()
+		next = append(next, task)
+	}
+	p.lock.Unlock()
+	for i := 0; i < spawn; i++ {
+		w := p.workerCache.Get().(*goWorkerWithFunc[T])
+		w.start()
+		workers = append(workers, w)
+	}
+	for i, w := range workers {
+		w.args <- next[i]
+	}
 }
 
 // revertWorker puts a worker back into free pool, recycling the goroutines.
-func (p *PoolWithFunc) revertWorker(worker *goWorkerWithFunc) bool {
-	if atomic.LoadInt32(&p.state) == CLOSED || p.Running() > p.Cap() {
-		return false
+// If there are invocations in the task queue, it returns the next one instead,
+// which must be run even if the pool has been closed.
+func (p *PoolWithFuncOf[T]) revertWorker(worker *goWorkerWithFunc[T]) (funcTask[T], bool) {
+	p.lock.Lock()
+	// Checking the task queue and putting the worker back must be done in one critical section,
+	// otherwise a task enqueued in between would never be consumed.
+	// The queue is left to the workers put back later when the concurrency limit is exceeded,
+	// unless the pool is closed, in which case all the accepted tasks must be run.
+	closed := atomic.LoadInt32(&p.state) == CLOSED
+	admitted := p.belowLimit(p.Running() - p.workers.len() - 1)
+	if closed || admitted {
+		if task, ok := p.tasks.pop(); ok {
+			p.lock.Unlock()
+			return task, true
+		}
+	}
+	if closed || p.Running() > p.Cap() {
+		p.lock.Unlock()
+		return funcTask[T]{}, false
 	}
 	worker.recycleTime = time.Now()
+	// Hand the worker over to the first invoker stuck in 'retrieveWorker()' directly,
+	// rather than putting it back and letting everyone race for it.
+	if admitted && p.waiters.handOff(worker) {
+		p.lock.Unlock()
+		return funcTask[T]{}, true
+	}
+	if err := p.workers.insert(worker); err != nil {
+		p.lock.Unlock()
+		return funcTask[T]{}, false
+	}
+	// There is one busy worker less now, give the waiting invokers another chance
+	// in case the concurrency limit has grown.
+	p.admitWaiters()
+	p.lock.Unlock()
+	return funcTask[T]{}, true
+}
+
+// discard notifies whoever waits for an accepted invocation that it has been discarded,
+// it must be called outside the lock.
+func (p *PoolWithFuncOf[T]) discard(args T) {
+	if p.dropped != nil {
+		p.dropped(args, ErrTaskDiscarded)
+	}
+}
+
+// userArgs returns args as the user callbacks receive it.
+func (p *PoolWithFuncOf[T]) userArgs(args T) interface{} {
+	if p.unwrap != nil {
+		return p.unwrap(args)
+	}
+	return args
+}
+
+// belowLimit reports whether the number of busy workers is below the concurrency limit,
+// it must be called with pool.lock held.
+func (p *PoolWithFuncOf[T]) belowLimit(busy int) bool {
+	l := p.options.ConcurrencyLimiter
+	return l == nil || busy < l.Limit()
+}
+
+// batchQuota returns how many of n tasks submitted in a batch can run right away
+// under the concurrency limit, it must be called with pool.lock held.
+func (p *PoolWithFuncOf[T]) batchQuota(n int) int {
+	if l := p.options.ConcurrencyLimiter; l != nil {
+		if free := l.Limit() - (p.Running() - p.workers.len()); free < n {
+			n = free
+		}
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// limit returns the number of tasks allowed to run concurrently.
+func (p *PoolWithFuncOf[T]) limit() int {
+	limit := p.Cap()
+	if l := p.options.ConcurrencyLimiter; l != nil && l.Limit() < limit {
+		limit = l.Limit()
+	}
+	return limit
+}
+
+// drainTasks spawns a new worker to consume the task queue after a worker exits from a panic
+// or the pool is grown, since the queued tasks can only be consumed by running workers.
+// Like InvokeBatch, it's bound by the concurrency limit and the capacity, which is reserved before unlocking.
+// It returns false if the task queue is empty or no more workers can be spawned.
+func (p *PoolWithFuncOf[T]) drainTasks() bool {
 	p.lock.Lock()
-	p.workers = append(p.workers, worker)
+	if p.Running() >= p.Cap() || !p.belowLimit(p.Running()-p.workers.len()) {
+		p.lock.Unlock()
+		return false
+	}
+	task, ok := p.tasks.pop()
+	if ok {
+		p.incRunning()
+	}
+	p.lock.Unlock()
+	if ok {
+		w := p.workerCache.Get().(*goWorkerWithFunc[T])
+		w.start()
+		w.args <- task
+	}
+	return ok
+}
 
-	// Notify the invoker stuck in 'retrieveWorker()' of there is an available worker in the worker queue.
-	p.cond.Signal()
+// wakeWaiter wakes up the first waiting invoker to spawn a new worker after a worker exits from a panic,
+// otherwise it wouldn't be woken up until the next purge.
+func (p *PoolWithFuncOf[T]) wakeWaiter() {
+	p.lock.Lock()
+	p.admitWaiters()
 	p.lock.Unlock()
-	return true
+}
+
+// admitWaiters lets the waiting invokers in when the pool is below the concurrency limit
+// (the limit has grown or the pool has room again), since they won't wake up by themselves:
+// the idle workers are handed over to the first ones directly, and if there is none but the pool
+// still has room, the first one is woken up to spawn a new worker. It must be called with pool.lock held.
+func (p *PoolWithFuncOf[T]) admitWaiters() {
+	for p.waiters.len() > 0 && p.belowLimit(p.Running()-p.workers.len()) {
+		if w := p.workers.detach(); w != nil {
+			p.waiters.handOff(w)
+			continue
+		}
+		if p.Running() < p.Cap() {
+			p.waiters.signal()
+		}
+		return
+	}
 }
@@ -0,0 +1,81 @@
+package ants
+
+import (
+	"context"
+	"time"
+)
+
+// resultCall is an invocation of PoolWithFuncResult along with the future of its result.
+type resultCall[T, R any] struct {
+	args   T
+	future *Future[R]
+}
+
+// resultPool hides the underlying pool so that its Invoke(resultCall) isn't reachable from outside,
+// while the rest of its methods (Running, Cap, Tune, Release, Stats, etc.) are still promoted.
+type resultPool[T, R any] struct {
+	*PoolWithFuncOf[resultCall[T, R]]
+}
+
+// PoolWithFuncResult is a PoolWithFuncOf whose function returns a result,
+// which is delivered through the Future returned by Invoke.
+type PoolWithFuncResult[T, R any] struct {
+	resultPool[T, R]
+}
+
+// NewPoolWithFuncResult generates an instance of ants pool with a specific function returning a result,
+// the function receives the context passed to InvokeContext (or context.Background() for Invoke).
+// A panic in pf is reported by the Future as a *PanicError before going to the PanicHandler.
+// The hooks and the RejectionHandler receive the arguments of Invoke. The Future of an invocation
+// that is never run completes with ErrTaskDiscarded if it's discarded by DiscardPolicy or DiscardOldestPolicy,
+// or with ErrPoolOverload if it's handed to the RejectionHandler.
+func NewPoolWithFuncResult[T, R any](size int, pf func(context.Context, T) (R, error), options ...Option) (*PoolWithFuncResult[T, R], error) {
+	if pf == nil {
+		return nil, ErrLackPoolFunc
+	}
+	p, err := NewPoolWithFuncOfContext(size, func(ctx context.Context, c resultCall[T, R]) {
+		c.future.run(func() (R, error) { return pf(ctx, c.args) })
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	p.unwrap = func(c resultCall[T, R]) interface{} {
+		return c.args
+	}
+	p.dropped = func(c resultCall[T, R], err error) {
+		c.future.fail(err)
+	}
+	return &PoolWithFuncResult[T, R]{resultPool[T, R]{p}}, nil
+}
+
+// Invoke submits a task to pool and returns the future of its result.
+func (p *PoolWithFuncResult[T, R]) Invoke(args T) (*Future[R], error) {
+	return p.InvokeContext(context.Background(), args)
+}
+
+// InvokeContext is like Invoke, but gives up waiting for an idle worker and returns ctx.Err() once ctx is done.
+func (p *PoolWithFuncResult[T, R]) InvokeContext(ctx context.Context, args T) (*Future[R], error) {
+	f := newFuture[R]()
+	if err := p.PoolWithFuncOf.InvokeContext(ctx, resultCall[T, R]{args, f}); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// InvokeWithPriority is like Invoke, but submits the task with the given priority, see PoolWithFuncOf.InvokeWithPriority.
+func (p *PoolWithFuncResult[T, R]) InvokeWithPriority(args T, priority int) (*Future[R], error) {
+	f := newFuture[R]()
+	if err := p.PoolWithFuncOf.InvokeWithPriority(resultCall[T, R]{args, f}, priority); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// InvokeTimeout is like Invoke, but gives up waiting for an idle worker and returns ErrSubmitTimeout after timeout.
+func (p *PoolWithFuncResult[T, R]) InvokeTimeout(args T, timeout time.Duration) (*Future[R], error) {
+	f := newFuture[R]()
+	if err := p.PoolWithFuncOf.InvokeTimeout(resultCall[T, R]{args, f}, timeout); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
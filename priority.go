@@ -0,0 +1,109 @@
+package ants
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//PriorityStats是某个优先级的统计数据
+type PriorityStats struct {
+	Submitted uint64 //该优先级被池子接受的任务总数
+	Waiting   int    //该优先级正在阻塞等待的提交者与任务队列中的任务数量
+}
+
+//设置优先级的老化间隔，等待者与排队的任务每等待interval，优先级就相当于提高1，
+//这样低优先级的任务也不会因为高优先级的任务源源不断而永远得不到执行，0表示不老化
+func WithPriorityAging(interval time.Duration) Option {
+	return func(opts *Options) {
+		opts.PriorityAging = interval
+	}
+}
+
+//priorityKey决定了等待者与排队的任务被服务的先后顺序：rank大的优先，rank相同时先来的优先
+type priorityKey struct {
+	priority int
+	rank     float64 //不老化时就是priority，老化时是 priority - 入队时间/老化间隔，这样先入队的就相当于随着时间不断提高了优先级
+	seq      uint64  //入队的序号
+}
+
+func (k *priorityKey) before(o *priorityKey) bool {
+	if k.rank != o.rank {
+		return k.rank > o.rank
+	}
+	return k.seq < o.seq
+}
+
+//priorityRanker为入队的等待者或者任务生成priorityKey，必须在持有池子锁的情况下调用
+type priorityRanker struct {
+	aging time.Duration
+	base  time.Time //计算入队时间的起点，避免rank的数值过大而丢失精度
+	seq   uint64
+}
+
+func newPriorityRanker(aging time.Duration) priorityRanker {
+	return priorityRanker{aging: aging, base: time.Now()}
+}
+
+func (r *priorityRanker) next(priority int) priorityKey {
+	r.seq++
+	k := priorityKey{priority: priority, rank: float64(priority), seq: r.seq}
+	if r.aging > 0 {
+		k.rank -= float64(time.Since(r.base)) / float64(r.aging)
+	}
+	return k
+}
+
+//prioritySubmitted按优先级统计被接受的任务数，只记录非0的优先级，
+//优先级0(即普通的Submit/Invoke)的数量由总数减去其他优先级得到，避免普通的提交也要读写map
+type prioritySubmitted struct {
+	mu     sync.RWMutex
+	counts map[int]*uint64
+}
+
+func (s *prioritySubmitted) add(priority int) {
+	s.mu.RLock()
+	c, ok := s.counts[priority]
+	s.mu.RUnlock()
+	if !ok {
+		s.mu.Lock()
+		if c, ok = s.counts[priority]; !ok {
+			if s.counts == nil {
+				s.counts = make(map[int]*uint64)
+			}
+			c = new(uint64)
+			s.counts[priority] = c
+		}
+		s.mu.Unlock()
+	}
+	atomic.AddUint64(c, 1)
+}
+
+func (s *prioritySubmitted) reset() {
+	s.mu.Lock()
+	s.counts = nil
+	s.mu.Unlock()
+}
+
+//将各优先级的任务数填充到快照中，waiting是各优先级正在等待的数量
+func (s *prioritySubmitted) load(ps *PoolStats, waiting map[int]int) {
+	priorities := make(map[int]PriorityStats, len(waiting)+1)
+	others := uint64(0)
+	s.mu.RLock()
+	for priority, c := range s.counts {
+		n := atomic.LoadUint64(c)
+		others += n
+		priorities[priority] = PriorityStats{Submitted: n}
+	}
+	s.mu.RUnlock()
+	//两次读取之间可能有新的任务被接受，避免相减溢出
+	if ps.Submitted > others {
+		priorities[0] = PriorityStats{Submitted: ps.Submitted - others}
+	}
+	for priority, n := range waiting {
+		stats := priorities[priority]
+		stats.Waiting = n
+		priorities[priority] = stats
+	}
+	ps.Priorities = priorities
+}
@@ -0,0 +1,27 @@
+package ants
+
+//RejectionPolicy表示池子饱和时(设置了Nonblocking、阻塞的任务数达到了MaxBlockingTasks或者任务队列满了)对新任务的处理策略，
+//参考了经典线程池的做法
+type RejectionPolicy int
+
+const (
+	//AbortPolicy直接返回ErrPoolOverload，这是默认的策略
+	AbortPolicy RejectionPolicy = iota
+	//CallerRunsPolicy在提交任务的goroutine上直接执行该任务，提交者会因此被拖慢，相当于一种天然的限流
+	CallerRunsPolicy
+	//DiscardOldestPolicy丢弃最老的那个任务(任务队列的队头或者等待最久的提交者)，为新任务腾出位置，
+	//没有可丢弃的任务时则丢弃新任务本身。被挤掉的提交者拿到的是ErrPoolOverload，
+	//已经被接受的任务被丢弃时，它的Future(以及所在的TaskGroup)以ErrTaskDiscarded结束
+	DiscardOldestPolicy
+	//DiscardPolicy静默丢弃新任务，提交者拿到的是nil，它的Future以ErrTaskDiscarded结束
+	DiscardPolicy
+)
+
+//RejectionHandler是自定义的拒绝处理函数，设置之后RejectionPolicy不再起作用，提交者拿到的是nil
+//对于Pool来说task是提交的func()，对于PoolWithFunc来说task是Invoke的参数
+type RejectionHandler func(task interface{})
+
+//是否采用了DiscardOldestPolicy，设置了自定义的拒绝处理函数时RejectionPolicy不起作用
+func (opts *Options) discardOldest() bool {
+	return opts.RejectionHandler == nil && opts.RejectionPolicy == DiscardOldestPolicy
+}
@@ -0,0 +1,170 @@
+package ants
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+//PoolStats是池子在某一时刻的统计快照，累计值都是从池子创建(或者Reboot)开始算起的
+type PoolStats struct {
+	Capacity       int                   //池子的容量
+	Limit          int                   //同时执行任务数的限制，即容量与ConcurrencyLimiter的限制值中较小的那个
+	Running        int                   //当前运行的worker(goroutine)数量，包括空闲的worker
+	Busy           int                   //正在执行任务的worker数量
+	Idle           int                   //空闲的worker数量
+	Waiting        int                   //阻塞等待空闲worker的提交者数量
+	Queued         int                   //任务队列中等待执行的任务数量
+	Submitted      uint64                //被池子接受的任务总数(交给了worker或者放入了任务队列)
+	Completed      uint64                //正常执行结束的任务总数
+	Rejected       uint64                //池子饱和时交给拒绝策略处理的任务总数
+	Discarded      uint64                //被拒绝策略丢弃的任务总数
+	TimedOut       uint64                //阻塞等待空闲worker超时而放弃提交的任务总数
+	Panicked       uint64                //执行时发生了panic的任务总数
+	WorkersSpawned uint64                //启动过的worker总数
+	WorkersPurged  uint64                //因为空闲过期而被清理掉的worker总数
+	ScaleUps       uint64                //自动扩容的次数
+	ScaleDowns     uint64                //自动缩容的次数
+	TaskDuration   time.Duration         //任务执行的累计耗时
+	WaitDuration   time.Duration         //任务从提交到开始执行的累计等待时间
+	TaskDurations  DurationHistogram     //任务执行耗时的分布
+	WaitDurations  DurationHistogram     //任务等待时间的分布
+	Priorities     map[int]PriorityStats //各优先级的统计数据，只包含接受过任务或者正在等待的优先级
+}
+
+//DurationHistogram是耗时的分布情况，Counts[i]是耗时落在(Buckets[i-1], Buckets[i]]区间内的次数，
+//Counts比Buckets多一个元素，最后一个是超过了所有Buckets的次数
+type DurationHistogram struct {
+	Buckets []time.Duration
+	Counts  []uint64
+}
+
+//统计耗时分布所用的区间上限，与Prometheus客户端默认的区间基本一致
+var durationBuckets = [...]time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+//durationCounts是按照durationBuckets划分的计数器
+type durationCounts [len(durationBuckets) + 1]uint64
+
+func (c *durationCounts) observe(d time.Duration) {
+	i := 0
+	for i < len(durationBuckets) && d > durationBuckets[i] {
+		i++
+	}
+	atomic.AddUint64(&c[i], 1)
+}
+
+func (c *durationCounts) reset() {
+	for i := range c {
+		atomic.StoreUint64(&c[i], 0)
+	}
+}
+
+func (c *durationCounts) load() DurationHistogram {
+	h := DurationHistogram{
+		Buckets: append([]time.Duration(nil), durationBuckets[:]...),
+		Counts:  make([]uint64, len(c)),
+	}
+	for i := range c {
+		h.Counts[i] = atomic.LoadUint64(&c[i])
+	}
+	return h
+}
+
+//poolStats是池子内部的累计计数器，全部通过原子操作来读写，
+//池子中以指针的形式持有它，保证64位的字段在32位平台上也是对齐的
+type poolStats struct {
+	lastWorkerID   uint64 //最近一次分配的worker编号，reset时不清零，保证worker的编号在池子内一直是唯一的
+	submitted      uint64
+	completed      uint64
+	rejected       uint64
+	discarded      uint64
+	timedOut       uint64
+	panicked       uint64
+	workersSpawned uint64
+	workersPurged  uint64
+	scaleUps       uint64
+	scaleDowns     uint64
+	taskDuration   int64
+	waitDuration   int64
+	taskDurations  durationCounts
+	waitDurations  durationCounts
+	priorities     prioritySubmitted
+}
+
+//任务被池子接受了
+func (s *poolStats) taskSubmitted(priority int) {
+	atomic.AddUint64(&s.submitted, 1)
+	if priority != 0 {
+		s.priorities.add(priority)
+	}
+}
+
+//任务开始执行，累加它的等待时间，返回开始执行的时间以及等待时间
+func (s *poolStats) taskStarted(since time.Time) (time.Time, time.Duration) {
+	now := time.Now()
+	wait := now.Sub(since)
+	atomic.AddInt64(&s.waitDuration, int64(wait))
+	s.waitDurations.observe(wait)
+	return now, wait
+}
+
+//任务正常执行结束，累加并返回它的执行耗时
+func (s *poolStats) taskCompleted(start time.Time) time.Duration {
+	d := time.Since(start)
+	atomic.AddUint64(&s.completed, 1)
+	atomic.AddInt64(&s.taskDuration, int64(d))
+	s.taskDurations.observe(d)
+	return d
+}
+
+//分配一个新的worker编号，同时累加启动过的worker数量
+func (s *poolStats) workerSpawned() uint64 {
+	atomic.AddUint64(&s.workersSpawned, 1)
+	return atomic.AddUint64(&s.lastWorkerID, 1)
+}
+
+//将累计计数器清零，Reboot时调用。此时可能还有worker在执行Release之前接受的任务，所以也必须使用原子操作
+func (s *poolStats) reset() {
+	for _, c := range []*uint64{&s.submitted, &s.completed, &s.rejected, &s.discarded, &s.timedOut, &s.panicked,
+		&s.workersSpawned, &s.workersPurged, &s.scaleUps, &s.scaleDowns} {
+		atomic.StoreUint64(c, 0)
+	}
+	atomic.StoreInt64(&s.taskDuration, 0)
+	atomic.StoreInt64(&s.waitDuration, 0)
+	s.taskDurations.reset()
+	s.waitDurations.reset()
+	s.priorities.reset()
+}
+
+//将累计计数器的值填充到快照中，waiting是此时各优先级正在等待的数量
+func (s *poolStats) load(ps *PoolStats, waiting map[int]int) {
+	ps.Submitted = atomic.LoadUint64(&s.submitted)
+	ps.Completed = atomic.LoadUint64(&s.completed)
+	ps.Rejected = atomic.LoadUint64(&s.rejected)
+	ps.Discarded = atomic.LoadUint64(&s.discarded)
+	ps.TimedOut = atomic.LoadUint64(&s.timedOut)
+	ps.Panicked = atomic.LoadUint64(&s.panicked)
+	ps.WorkersSpawned = atomic.LoadUint64(&s.workersSpawned)
+	ps.WorkersPurged = atomic.LoadUint64(&s.workersPurged)
+	ps.ScaleUps = atomic.LoadUint64(&s.scaleUps)
+	ps.ScaleDowns = atomic.LoadUint64(&s.scaleDowns)
+	ps.TaskDuration = time.Duration(atomic.LoadInt64(&s.taskDuration))
+	ps.WaitDuration = time.Duration(atomic.LoadInt64(&s.waitDuration))
+	ps.TaskDurations = s.taskDurations.load()
+	ps.WaitDurations = s.waitDurations.load()
+	s.priorities.load(ps, waiting)
+}
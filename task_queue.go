@@ -0,0 +1,127 @@
+package ants
+
+import "time"
+
+//taskQueue是一个定长的优先队列(二叉堆)，用来缓存池子满载时提交进来的任务，优先级相同时先进先出
+//与worker的loopQueue一样，它的所有方法都必须在持有池子锁的情况下调用。
+//容量为0的taskQueue(即未开启任务队列)永远是空的，也永远放不进任务
+type taskQueue[T any] struct {
+	items    []queuedTask[T]
+	ranker   priorityRanker
+	reserved int //已经预留但任务还没有放进来的位置个数
+}
+
+type queuedTask[T any] struct {
+	key  priorityKey
+	task T
+}
+
+func newTaskQueue[T any](capacity int, aging time.Duration) taskQueue[T] {
+	return taskQueue[T]{items: make([]queuedTask[T], 0, capacity), ranker: newPriorityRanker(aging)}
+}
+
+//获取队列中任务的个数
+func (q *taskQueue[T]) len() int {
+	return len(q.items)
+}
+
+//队列的容量
+func (q *taskQueue[T]) cap() int {
+	return cap(q.items)
+}
+
+//以priority的优先级往队列中添加一个任务，队列已满(包括预留的位置)则返回false
+func (q *taskQueue[T]) push(task T, priority int) bool {
+	if len(q.items)+q.reserved >= cap(q.items) {
+		return false
+	}
+	q.items = append(q.items, queuedTask[T]{q.ranker.next(priority), task})
+	q.up(len(q.items) - 1)
+	return true
+}
+
+//为一个任务预留位置，之后再通过unreserve+push放进来，队列已满则返回false。
+//用来在任务对worker可见之前先触发提交的回调以及统计，同时又保证它一定放得进来
+func (q *taskQueue[T]) reserve() bool {
+	if len(q.items)+q.reserved >= cap(q.items) {
+		return false
+	}
+	q.reserved++
+	return true
+}
+
+//释放n个预留的位置
+func (q *taskQueue[T]) unreserve(n int) {
+	q.reserved -= n
+}
+
+//取出优先级最高的任务
+func (q *taskQueue[T]) pop() (task T, ok bool) {
+	if len(q.items) == 0 {
+		return task, false
+	}
+	return q.remove(0), true
+}
+
+//丢弃入队最早的那个任务并返回它，以便通知它的提交者，队列为空时返回false
+func (q *taskQueue[T]) discardOldest() (task T, ok bool) {
+	if len(q.items) == 0 {
+		return task, false
+	}
+	oldest := 0
+	for i := range q.items {
+		if q.items[i].key.seq < q.items[oldest].key.seq {
+			oldest = i
+		}
+	}
+	return q.remove(oldest), true
+}
+
+//统计各优先级的任务数量，累加到counts中
+func (q *taskQueue[T]) countPriorities(counts map[int]int) {
+	for i := range q.items {
+		counts[q.items[i].key.priority]++
+	}
+}
+
+func (q *taskQueue[T]) remove(i int) T {
+	task := q.items[i].task
+	n := len(q.items) - 1
+	q.items[i] = q.items[n]
+	q.items[n] = queuedTask[T]{} //避免队列继续持有任务的引用
+	q.items = q.items[:n]
+	if i < n {
+		q.down(i)
+		q.up(i)
+	}
+	return task
+}
+
+func (q *taskQueue[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !q.items[i].key.before(&q.items[parent].key) {
+			break
+		}
+		q.items[i], q.items[parent] = q.items[parent], q.items[i]
+		i = parent
+	}
+}
+
+func (q *taskQueue[T]) down(i int) {
+	n := len(q.items)
+	for {
+		first, left := i, 2*i+1
+		if left < n && q.items[left].key.before(&q.items[first].key) {
+			first = left
+		}
+		if right := left + 1; right < n && q.items[right].key.before(&q.items[first].key) {
+			first = right
+		}
+		if first == i {
+			return
+		}
+		q.items[i], q.items[first] = q.items[first], q.items[i]
+		i = first
+	}
+}
@@ -0,0 +1,77 @@
+package ants
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskQueue(t *testing.T) {
+	q := newTaskQueue[int](3, 0)
+	assert.EqualValues(t, 0, q.len(), "Len error")
+	assert.EqualValues(t, 3, q.cap(), "Cap error")
+	_, ok := q.pop()
+	assert.False(t, ok, "Dequeue error")
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, q.push(i, 0), "Enqueue error")
+	}
+	assert.False(t, q.push(3, 0), "Enqueue into a full queue should fail")
+	v, _ := q.pop()
+	assert.EqualValues(t, 0, v, "Dequeue error")
+	assert.True(t, q.push(3, 0), "Enqueue error")
+	for i := 1; i <= 3; i++ {
+		v, ok = q.pop()
+		assert.True(t, ok, "Dequeue error")
+		assert.EqualValues(t, i, v, "Dequeue should be FIFO")
+	}
+	assert.EqualValues(t, 0, q.len(), "Len error")
+
+	disabled := newTaskQueue[int](0, 0)
+	assert.False(t, disabled.push(1, 0), "Enqueue into a disabled queue should fail")
+}
+
+func TestTaskQueuePriority(t *testing.T) {
+	q := newTaskQueue[int](5, 0)
+	for i, priority := range []int{0, 2, 1, 2, -1} {
+		assert.True(t, q.push(i, priority), "Enqueue error")
+	}
+	counts := make(map[int]int)
+	q.countPriorities(counts)
+	assert.EqualValues(t, map[int]int{-1: 1, 0: 1, 1: 1, 2: 2}, counts)
+
+	//丢弃的是最早入队的任务，而不是优先级最低的任务
+	v, ok := q.discardOldest()
+	assert.True(t, ok, "Discard error")
+	assert.EqualValues(t, 0, v, "the oldest task should be discarded")
+	for _, want := range []int{1, 3, 2, 4} {
+		v, ok := q.pop()
+		assert.True(t, ok, "Dequeue error")
+		assert.EqualValues(t, want, v, "Dequeue should follow priority, then FIFO")
+	}
+	_, ok = q.discardOldest()
+	assert.False(t, ok, "Discard from an empty queue should fail")
+}
+
+func TestTaskQueueReserve(t *testing.T) {
+	q := newTaskQueue[int](2, 0)
+	assert.True(t, q.reserve(), "Reserve error")
+	assert.True(t, q.push(0, 0), "Enqueue error")
+	//预留的位置也算占用了容量
+	assert.False(t, q.push(1, 0), "Enqueue into a queue full of reserved slots should fail")
+	assert.False(t, q.reserve(), "Reserve from a full queue should fail")
+	assert.EqualValues(t, 1, q.len(), "reserved slots shouldn't be counted as tasks")
+	q.unreserve(1)
+	assert.True(t, q.push(1, 0), "Enqueue into the released slot error")
+}
+
+func TestTaskQueuePriorityAging(t *testing.T) {
+	q := newTaskQueue[int](2, time.Millisecond)
+	assert.True(t, q.push(0, 0), "Enqueue error")
+	time.Sleep(20 * time.Millisecond)
+	//等待了20个老化间隔的任务比刚入队的优先级为10的任务更早被执行
+	assert.True(t, q.push(1, 10), "Enqueue error")
+	v, _ := q.pop()
+	assert.EqualValues(t, 0, v, "Aged task should be dequeued first")
+}
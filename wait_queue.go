@@ -0,0 +1,163 @@
+package ants
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+//waiter表示一个卡在retrieveWorker中等待空闲worker的提交者
+type waiter struct {
+	ready     chan struct{} //被唤醒时会往该通道写入一个信号
+	key       priorityKey   //决定被唤醒的先后顺序，被唤醒之后重新等待时沿用，不会失去原来的位置
+	index     int           //在等待队列(堆)中的下标，方便被取消时直接移除，不在队列中时为-1
+	worker    worker        //通过handOff直接交到它手上的worker
+	discarded bool          //是否是被DiscardOldestPolicy挤掉而唤醒的
+}
+
+//waiterHeap实现了heap.Interface，堆顶是最先被唤醒的等待者
+type waiterHeap []*waiter
+
+func (h waiterHeap) Len() int           { return len(h) }
+func (h waiterHeap) Less(i, j int) bool { return h[i].key.before(&h[j].key) }
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *waiterHeap) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+func (h *waiterHeap) Pop() interface{} {
+	old := *h
+	n := len(old) - 1
+	w := old[n]
+	old[n] = nil
+	w.index = -1
+	*h = old[:n]
+	return w
+}
+
+//waitQueue用来替换原来的sync.Cond，与条件变量一样，它的所有方法都必须在持有池子锁的情况下调用。
+//不同的是每个等待者都有自己专属的通道，因此可以按照优先级(相同时按照先来后到)的顺序被逐个唤醒，
+//也可以在context被取消时从队列中单独摘除，而不会"吞掉"本该属于别人的唤醒信号。
+//放回的worker通过handOff直接交给排在最前面的等待者，而不是放回空闲队列再唤醒它去抢，
+//这样在它重新获取锁之前，新来的提交者就没有机会插队，保证了等待者严格按照顺序拿到worker。
+type waitQueue struct {
+	waiters waiterHeap
+	ranker  priorityRanker
+}
+
+func newWaitQueue(aging time.Duration) waitQueue {
+	return waitQueue{ranker: newPriorityRanker(aging)}
+}
+
+//len返回当前正在等待的提交者个数
+func (q *waitQueue) len() int {
+	return len(q.waiters)
+}
+
+//newWaiter为以priority的优先级提交的调用者创建一个等待者，此时就确定了它的排队顺序
+func (q *waitQueue) newWaiter(priority int) *waiter {
+	return &waiter{ready: make(chan struct{}, 1), key: q.ranker.next(priority), index: -1}
+}
+
+//wait将等待者w挂到队列中，并释放锁等待被唤醒，返回前会重新获取锁。
+//通过handOff被唤醒时返回交给它的worker，通过signal或者broadcast被唤醒时返回nil，调用者需要自己重新检查池子的状态；
+//如果在被唤醒之前ctx就已经结束了，则返回ctx.Err()；到了deadline(非零值)还没被唤醒则返回ErrSubmitTimeout；
+//如果是被挤掉的，则返回ErrTaskDiscarded
+func (q *waitQueue) wait(ctx context.Context, l sync.Locker, w *waiter, deadline time.Time) (worker, error) {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return nil, ErrSubmitTimeout
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	heap.Push(&q.waiters, w)
+	l.Unlock()
+
+	var err error
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = ErrSubmitTimeout
+	}
+	l.Lock()
+	if err != nil {
+		select {
+		case <-w.ready:
+			//放弃与唤醒同时发生，已经交到手上的worker就照常使用，
+			//单纯的唤醒信号则需要转交给下一个等待者，否则就丢失了
+			if w.worker == nil && !w.discarded {
+				q.signal()
+				return nil, err
+			}
+		default:
+			heap.Remove(&q.waiters, w.index)
+			return nil, err
+		}
+	}
+	if w.discarded {
+		return nil, ErrTaskDiscarded
+	}
+	wk := w.worker
+	w.worker = nil
+	return wk, nil
+}
+
+//handOff将worker直接交给排在最前面的等待者，没有等待者时返回false
+func (q *waitQueue) handOff(wk worker) bool {
+	if len(q.waiters) == 0 {
+		return false
+	}
+	w := heap.Pop(&q.waiters).(*waiter)
+	w.worker = wk
+	w.ready <- struct{}{}
+	return true
+}
+
+//signal唤醒排在最前面的那个等待者
+func (q *waitQueue) signal() {
+	if len(q.waiters) > 0 {
+		heap.Pop(&q.waiters).(*waiter).ready <- struct{}{}
+	}
+}
+
+//discardOldest唤醒等待最久的那个等待者并告知它被挤掉了，队列为空时返回false
+func (q *waitQueue) discardOldest() bool {
+	if len(q.waiters) == 0 {
+		return false
+	}
+	oldest := 0
+	for i, w := range q.waiters {
+		if w.key.seq < q.waiters[oldest].key.seq {
+			oldest = i
+		}
+	}
+	w := heap.Remove(&q.waiters, oldest).(*waiter)
+	w.discarded = true
+	w.ready <- struct{}{}
+	return true
+}
+
+//broadcast唤醒所有的等待者
+func (q *waitQueue) broadcast() {
+	for len(q.waiters) > 0 {
+		q.signal()
+	}
+}
+
+//统计各优先级的等待者数量，累加到counts中
+func (q *waitQueue) countPriorities(counts map[int]int) {
+	for _, w := range q.waiters {
+		counts[w.key.priority]++
+	}
+}
@@ -2,6 +2,7 @@ package ants
 
 import (
 	"runtime"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,23 +13,37 @@ import (
 
 type goWorker struct {
 	pool *Pool //拥有该worker的池子指针
-	task chan func() //任务回调函数
+	task chan poolTask //要执行的任务，没有回调函数的任务表示worker该退出了
 	recycleTime time.Time //将worker重新放入队列时，recycleTime将被更新。
+	id uint64 //worker的编号，每次启动时分配，在池子内唯一
 }
 //运行启动goroutine以重复该过程,执行函数调用。
 //@reviser sam@2020-04-18 09:07:26
 func (w *goWorker) run() {
 	//增加当前运行的worker的数量
 	w.pool.incRunning()
+	w.start()
+}
+
+//与run一样启动worker，但是不增加当前运行的worker的数量，调用者需要事先在持有锁的情况下增加，
+//批量开启worker时用来预留池子的余量，避免解锁之后被别的提交者抢先开启worker而超过容量
+func (w *goWorker) start() {
+	w.id = w.pool.stats.workerSpawned()
+	w.pool.options.Hooks.workerSpawn(w.id)
 	//开启一个G执行worker要处理的任务
 	go func() {
+		executing := false //是否正在执行任务，任务发生panic时用来修正inflight
 		//捕获一些错误
 		defer func() {
-			//@todo 只要该函数结束，不管错不错都会执行这两句
+			if executing {
+				atomic.AddInt32(&w.pool.inflight, -1)
+			}
+			//@todo 只要该函数结束，不管错不错都会减少正在运行的worker个数，并在最后放回临时对象池
 			w.pool.decRunning() //正在运行的w个数减一
-			w.pool.workerCache.Put(w) //worker开启groutine之后，出现恐慌的，则会被放入临时对象池中额
 			//-------
 			if p := recover(); p != nil {
+				atomic.AddUint64(&w.pool.stats.panicked, 1)
+				w.pool.options.Hooks.panic(w.id, p)
 				//有自定义的按照自定义的处理
 				if ph := w.pool.options.PanicHandler; ph != nil {
 					ph(p)
@@ -39,19 +54,57 @@ func (w *goWorker) run() {
 					n := runtime.Stack(buf[:], false)
 					w.pool.options.Logger.Printf("worker exits from panic: %s\n", string(buf[:n]))
 				}
+				w.pool.drainTasks()
+				w.pool.wakeWaiter()
+			}
+			//panic的任务也算执行结束了
+			if executing {
+				w.pool.pending.done()
 			}
+			//最后才放入临时对象池，否则它有可能被别的提交者取出来重新开启，上面用到的w.id就被改掉了
+			w.pool.workerCache.Put(w)
 		}()
 		// 循环监听取出的w的任务通道，一旦有任务立马取出运行
-		for f := range w.task {
-			//提交的任务回调是nil，则没有必要开启一个新的worker
-			if f == nil {
+		for task := range w.task {
+			//收到的是退出信号，提交的nil任务已经在Submit中被拒绝了，所以不会与之混淆
+			if task.fn == nil {
 				return
 			}
-			f()
-			//执行完任务就将worker放入items中
-			if ok := w.pool.revertWorker(w); !ok {
-				return
+			//执行完任务就将worker放入items中，任务队列中有积压的任务则接着执行
+			for task.fn != nil {
+				start, wait := w.pool.stats.taskStarted(task.since)
+				w.pool.options.Hooks.taskStart(w.id, wait)
+				inflight := atomic.AddInt32(&w.pool.inflight, 1)
+				executing = true
+				task.fn()
+				executing = false
+				atomic.AddInt32(&w.pool.inflight, -1)
+				run := w.pool.stats.taskCompleted(start)
+				w.pool.options.Hooks.taskEnd(w.id, wait, run)
+				if l := w.pool.options.ConcurrencyLimiter; l != nil {
+					l.Observe(run, int(inflight))
+				}
+				w.pool.pending.done()
+				var ok bool
+				if task, ok = w.pool.revertWorker(w); !ok {
+					return
+				}
 			}
 		}
 	}()
 }
+
+//通知worker退出
+func (w *goWorker) stop() {
+	w.task <- poolTask{}
+}
+
+//worker最近一次放回池子的时间
+func (w *goWorker) lastUsedTime() time.Time {
+	return w.recycleTime
+}
+
+//worker的编号
+func (w *goWorker) workerID() uint64 {
+	return w.id
+}
@@ -10,6 +10,14 @@ var (
 	errQueueIsReleased = errors.New("the queue length is zero")
 )
 
+//worker是workerArray中存放的空闲worker，goWorker与goWorkerWithFunc都实现了该接口，
+//这样两种池子就可以共用同一套workers容器了，从容器中取出之后再断言回各自的具体类型
+type worker interface {
+	lastUsedTime() time.Time //最近一次放回容器的时间，用来判断是否过期
+	workerID() uint64        //worker在池子内的编号
+	stop()                   //通知worker退出
+}
+
 //------------------------------------  workerArray接口  -----------------------------
 //@todo v1版本对workers的存储就是 workers []*Worker,显得不够儒雅，这里我们通过定义统一的接口workerArray来对其进行进一步封装
 //workers是一个slice，用来存放空闲worker，请求进入Pool之后会首先检查workers中是否有空闲worker，若有则取出绑定任务执行，
@@ -17,9 +25,10 @@ var (
 type workerArray interface {
 	len() int
 	isEmpty() bool
-	insert(worker *goWorker) error
-	detach() *goWorker
-	retrieveExpiry(duration time.Duration) []*goWorker
+	insert(w worker) error
+	detach() worker
+	retrieveExpiry(duration time.Duration) []worker
+	trim(n int) []worker
 	reset()
 }
 
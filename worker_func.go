@@ -24,32 +24,49 @@ package ants
 
 import (
 	"runtime"
+	"sync/atomic"
 	"time"
 )
 
 // goWorkerWithFunc is the actual executor who runs the tasks,
 // it starts a goroutine that accepts tasks and
 // performs function calls.
-type goWorkerWithFunc struct {
+type goWorkerWithFunc[T any] struct {
 	// pool who owns this worker.
-	pool *PoolWithFunc
+	pool *PoolWithFuncOf[T]
 
-	// args is a job should be done.
-	args chan interface{}
+	// args is a job should be done, a job without ctx means the worker should exit.
+	args chan funcTask[T]
 
 	// recycleTime will be update when putting a worker back into queue.
 	recycleTime time.Time
+
+	// id identifies the worker within its pool, it's assigned every time the worker starts.
+	id uint64
 }
 
 // run starts a goroutine to repeat the process
 // that performs the function calls.
-func (w *goWorkerWithFunc) run() {
+func (w *goWorkerWithFunc[T]) run() {
 	w.pool.incRunning()
+	w.start()
+}
+
+// start is run without increasing pool.running, which must have been done by the caller.
+func (w *goWorkerWithFunc[T]) start() {
+	w.id = w.pool.stats.workerSpawned()
+	w.pool.options.Hooks.workerSpawn(w.id)
 	go func() {
+		// executing is used to correct pool.inflight when the task panics.
+		executing := false
 		defer func() {
+			if executing {
+				atomic.AddInt32(&w.pool.inflight, -1)
+			}
 			w.pool.decRunning()
-			w.pool.workerCache.Put(w)
 			if p := recover(); p != nil {
+				atomic.AddUint64(&w.pool.stats.panicked, 1)
+				w.pool.options.Hooks.panic(w.id, p)
 				if ph := w.pool.options.PanicHandler; ph != nil {
 					ph(p)
 				} else {
@@ -58,17 +75,57 @@ func (w *goWorkerWithFunc) run() {
 					n := runtime.Stack(buf[:], false)
 					w.pool.options.Logger.Printf("worker with func exits from panic: %s\n", string(buf[:n]))
 				}
+				w.pool.drainTasks()
+				w.pool.wakeWaiter()
+			}
+			// The panicked task is finished as well.
+			if executing {
+				w.pool.pending.done()
 			}
+			// Put it back to the cache at last, otherwise it might be restarted by another submitter,
+			// changing the w.id used above.
+			w.pool.workerCache.Put(w)
 		}()
 
-		for args := range w.args {
-			if args == nil {
+		for task := range w.args {
+			if task.ctx == nil {
 				return
 			}
-			w.pool.poolFunc(args)
-			if ok := w.pool.revertWorker(w); !ok {
-				return
+			// Keep running the tasks from the task queue until it's empty.
+			for task.ctx != nil {
+				start, wait := w.pool.stats.taskStarted(task.since)
+				w.pool.options.Hooks.taskStart(w.id, wait)
+				inflight := atomic.AddInt32(&w.pool.inflight, 1)
+				executing = true
+				w.pool.poolFunc(task.ctx, task.args)
+				executing = false
+				atomic.AddInt32(&w.pool.inflight, -1)
+				run := w.pool.stats.taskCompleted(start)
+				w.pool.options.Hooks.taskEnd(w.id, wait, run)
+				if l := w.pool.options.ConcurrencyLimiter; l != nil {
+					l.Observe(run, int(inflight))
+				}
+				w.pool.pending.done()
+				var ok bool
+				if task, ok = w.pool.revertWorker(w); !ok {
+					return
+				}
 			}
 		}
 	}()
 }
+
+// stop notifies the worker to exit, it's a job without ctx so that any args, even nil, is a legal job.
+func (w *goWorkerWithFunc[T]) stop() {
+	w.args <- funcTask[T]{}
+}
+
+// lastUsedTime returns the time when the worker was put back into the pool last time.
+func (w *goWorkerWithFunc[T]) lastUsedTime() time.Time {
+	return w.recycleTime
+}
+
+// workerID returns the id of the worker.
+func (w *goWorkerWithFunc[T]) workerID() uint64 {
+	return w.id
+}
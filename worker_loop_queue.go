@@ -5,8 +5,8 @@ import "time"
 //@todo 这里是用切片实现了一个队列机制吧了,先进先出额
 //@todo [head] w1 w2 w3 w4 w5 ... [tail]  ===>每次添加都是从tail处，每次获取都是从head处
 type loopQueue struct {
-	items  []*goWorker
-	expiry []*goWorker
+	items  []worker
+	expiry []worker
 	head   int  //标记最后一个取出元素的下标参考值
 	tail   int  //标记最后一个元素的下标参考值
 	size   int //队列长度，一般是与池子中的size值一致
@@ -41,7 +41,7 @@ func (wq *loopQueue) isEmpty() bool {
 
 //往队列的尾部添加一个worker
 //@reviser sam@2020-04-18 10:55:37
-func (wq *loopQueue) insert(worker *goWorker) error {
+func (wq *loopQueue) insert(worker worker) error {
 	//当前队列的缓存长度是否为0
 	if wq.size == 0 {
 		return errQueueIsReleased
@@ -65,7 +65,7 @@ func (wq *loopQueue) insert(worker *goWorker) error {
 }
 //从队列的头部取出一个元素
 //@reviser sam@2020-04-18 11:09:16
-func (wq *loopQueue) detach() *goWorker {
+func (wq *loopQueue) detach() worker {
 	//判断队列是否已经空了
 	if wq.isEmpty() {
 		return nil
@@ -83,7 +83,7 @@ func (wq *loopQueue) detach() *goWorker {
 	return w
 }
 
-func (wq *loopQueue) retrieveExpiry(duration time.Duration) []*goWorker {
+func (wq *loopQueue) retrieveExpiry(duration time.Duration) []worker {
 	if wq.isEmpty() {
 		return nil
 	}
@@ -92,7 +92,7 @@ func (wq *loopQueue) retrieveExpiry(duration time.Duration) []*goWorker {
 	expiryTime := time.Now().Add(-duration)
 
 	for !wq.isEmpty() {
-		if expiryTime.Before(wq.items[wq.head].recycleTime) {
+		if expiryTime.Before(wq.items[wq.head].lastUsedTime()) {
 			break
 		}
 		wq.expiry = append(wq.expiry, wq.items[wq.head])
@@ -106,6 +106,38 @@ func (wq *loopQueue) retrieveExpiry(duration time.Duration) []*goWorker {
 	return wq.expiry
 }
 
+//取出队头最早放回来的n个worker，不足n个则全部取出，池子缩容时用来淘汰多余的空闲worker
+func (wq *loopQueue) trim(n int) []worker {
+	var trimmed []worker
+	for ; n > 0 && !wq.isEmpty(); n-- {
+		trimmed = append(trimmed, wq.detach())
+	}
+	return trimmed
+}
+
+//调整队列的长度，重新分配items并保持worker原有的先后顺序(即recycleTime由远及近)，
+//新的长度容纳不下所有的worker时，丢弃队头那些最早放回来的并返回，由调用方通知它们退出
+func (wq *loopQueue) resize(size int) []worker {
+	var surplus []worker
+	n := wq.len()
+	for ; n > size; n-- {
+		surplus = append(surplus, wq.detach())
+	}
+	items := make([]worker, size)
+	for i := 0; i < n; i++ {
+		items[i] = wq.detach()
+	}
+	wq.items = items
+	wq.size = size
+	wq.head = 0
+	wq.tail = n
+	if wq.tail == size {
+		wq.tail = 0
+	}
+	wq.isFull = size > 0 && n == size
+	return surplus
+}
+
 //恢复出厂设置
 //@reviser sam@2020-04-18 14:31:02
 func (wq *loopQueue) reset() {
@@ -115,7 +147,7 @@ func (wq *loopQueue) reset() {
 
 Releasing:
 	if w := wq.detach(); w != nil {
-		w.task <- nil
+		w.stop()
 		goto Releasing
 	}
 	wq.items = wq.items[:0]
@@ -129,7 +161,7 @@ Releasing:
 //@reviser sam@2020-04-18 10:21:41
 func newWorkerLoopQueue(size int) *loopQueue {
 	return &loopQueue{
-		items: make([]*goWorker, size),
+		items: make([]worker, size),
 		size:  size,
 	}
 }
\ No newline at end of file
@@ -46,3 +46,40 @@ func TestLoopQueue(t *testing.T) {
 	q.retrieveExpiry(time.Second)
 	assert.EqualValuesf(t, 6, q.len(), "Len error: %d", q.len())
 }
+
+func TestLoopQueueResize(t *testing.T) {
+	q := newWorkerLoopQueue(4)
+	base := time.Now()
+	// Make the queue wrap around before resizing.
+	for i := 0; i < 4; i++ {
+		_ = q.insert(&goWorker{recycleTime: base.Add(time.Duration(i))})
+	}
+	q.detach()
+	q.detach()
+	for i := 4; i < 6; i++ {
+		_ = q.insert(&goWorker{recycleTime: base.Add(time.Duration(i))})
+	}
+	assert.EqualValues(t, 4, q.len(), "Len error")
+
+	surplus := q.resize(8)
+	assert.Empty(t, surplus, "no worker should be dropped when growing")
+	assert.EqualValues(t, 4, q.len(), "Len error")
+	for i := 6; i < 10; i++ {
+		assert.NoError(t, q.insert(&goWorker{recycleTime: base.Add(time.Duration(i))}), "Enqueue error")
+	}
+	assert.Error(t, q.insert(&goWorker{recycleTime: time.Now()}), "Enqueue error")
+
+	surplus = q.resize(3)
+	assert.EqualValues(t, 3, q.len(), "Len error")
+	assert.Len(t, surplus, 5, "the oldest workers should be dropped when shrinking")
+	for i, w := range surplus {
+		assert.Equal(t, base.Add(time.Duration(i+2)), w.lastUsedTime(), "surplus should be the oldest workers")
+	}
+	for i := 7; i < 10; i++ {
+		assert.Equal(t, base.Add(time.Duration(i)), q.detach().lastUsedTime(), "order should be preserved")
+	}
+	assert.True(t, q.isEmpty(), "IsEmpty error")
+
+	assert.Empty(t, q.resize(0))
+	assert.Error(t, q.insert(&goWorker{recycleTime: time.Now()}), "Enqueue error")
+}
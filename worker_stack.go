@@ -6,8 +6,8 @@ import (
 
 //实现workerArray接口,6个方法额
 type workerStack struct {
-	items  []*goWorker
-	expiry []*goWorker
+	items  []worker
+	expiry []worker
 	size   int
 }
 
@@ -20,7 +20,7 @@ func (wq *workerStack) isEmpty() bool {
 	return len(wq.items) == 0
 }
 //往items中追加一个元素
-func (wq *workerStack) insert(worker *goWorker) error {
+func (wq *workerStack) insert(worker worker) error {
 	wq.items = append(wq.items, worker)
 	return nil
 }
@@ -29,7 +29,7 @@ func (wq *workerStack) insert(worker *goWorker) error {
 //后进(最后进来的那个索引是最大的额)的证明是最近活跃的worker，所以优先它们出去
 //w1 w2  w3  w4  ... w10 w11  w12 ...因为越靠前就是越接近过期,所以从尾部取
 //@todo v1版本有bug,直接就从workers中获取,有可能尾部的那个就是失效的额
-func (wq *workerStack) detach() *goWorker {
+func (wq *workerStack) detach() worker {
 	l := wq.len()
 	if l == 0 {
 		return nil
@@ -45,7 +45,7 @@ func (wq *workerStack) detach() *goWorker {
 //因为采用了LIFO后进先出  栈的结构存放空闲worker，所以该workers默认已经是按照worker的最后运行时间由远及近排序，
 //w1 w2  w3  w4  ... w10 w11  w12 ...   如果判断出w10过期了，那么w1-9必然也过期了
 //@reviser sam@2020-04-17 14:54:30
-func (wq *workerStack) retrieveExpiry(duration time.Duration) []*goWorker {
+func (wq *workerStack) retrieveExpiry(duration time.Duration) []worker {
 	n := wq.len()
 	if n == 0 {
 		return nil
@@ -79,7 +79,7 @@ func (wq *workerStack) binarySearch(l, r int, expiryTime time.Time) int {
 	//查找结束的条件是最右index不再大于最左边的index了。
 	for l <= r {
 		mid = (l + r) / 2 //这里由于mid是int类型，所以会自己舍弃小数的
-		if expiryTime.Before(wq.items[mid].recycleTime) { //中间的未过期===>向左查找
+		if expiryTime.Before(wq.items[mid].lastUsedTime()) { //中间的未过期===>向左查找
 			r = mid - 1
 		} else {   //中间的已经过期了,左边肯定已经全过期了，所以想进一步靠近临界值，l得变成mid+1 ===>向右查找
 			l = mid + 1
@@ -88,11 +88,28 @@ func (wq *workerStack) binarySearch(l, r int, expiryTime time.Time) int {
 	return r
 }
 
+//取出最早放回来的n个worker，不足n个则全部取出，池子缩容时用来淘汰多余的空闲worker
+func (wq *workerStack) trim(n int) []worker {
+	if n > wq.len() {
+		n = wq.len()
+	}
+	if n <= 0 {
+		return nil
+	}
+	trimmed := append([]worker(nil), wq.items[:n]...)
+	m := copy(wq.items, wq.items[n:])
+	for i := m; i < wq.len(); i++ {
+		wq.items[i] = nil
+	}
+	wq.items = wq.items[:m]
+	return trimmed
+}
+
 //恢复出厂设置
 //@reviser sam@2020-04-18 10:00:54
 func (wq *workerStack) reset() {
 	for i := 0; i < wq.len(); i++ {
-		wq.items[i].task <- nil
+		wq.items[i].stop()
 	}
 	wq.items = wq.items[:0]
 }
@@ -102,7 +119,7 @@ func (wq *workerStack) reset() {
 //@reviser sam@2020-04-17 14:27:43
 func newWorkerStack(size int) *workerStack {
 	return &workerStack{
-		items: make([]*goWorker, 0, size),
+		items: make([]worker, 0, size),
 		size:  size,
 	}
 }
\ No newline at end of file
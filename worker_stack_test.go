@@ -86,3 +86,20 @@ func TestSearch(t *testing.T) {
 
 	assert.EqualValues(t, 7, q.binarySearch(0, q.len()-1, expiry3), "index should be 7")
 }
+
+func TestWorkerStackTrim(t *testing.T) {
+	q := newWorkerStack(0)
+	base := time.Now()
+	for i := 0; i < 5; i++ {
+		_ = q.insert(&goWorker{recycleTime: base.Add(time.Duration(i))})
+	}
+	trimmed := q.trim(2)
+	assert.Len(t, trimmed, 2)
+	assert.Equal(t, base, trimmed[0].lastUsedTime(), "the oldest workers should be trimmed")
+	assert.Equal(t, base.Add(1), trimmed[1].lastUsedTime(), "the oldest workers should be trimmed")
+	assert.EqualValues(t, 3, q.len(), "Len error")
+	assert.Equal(t, base.Add(4), q.detach().lastUsedTime(), "the newest worker should be kept")
+	assert.Len(t, q.trim(10), 2)
+	assert.Nil(t, q.trim(1))
+	assert.True(t, q.isEmpty(), "IsEmpty error")
+}